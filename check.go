@@ -2,18 +2,34 @@
 // 编译说明:
 //   Linux 静态编译:    CGO_ENABLED=0 GOOS=linux go build -ldflags="-s -w" -o check_linux check.go
 //   Windows 静态编译:  CGO_ENABLED=0 GOOS=windows go build -ldflags="-s -w" -o check.exe check.go
+// 用法:
+//   ./check_linux                      # 采集前检测
+//   ./check_linux -watch -interval=60s # 检测通过后持续监控，异常时告警响铃
+//   ./check_linux -jump=192.168.30.43  # 经车内网关（跳板机）连接各主机
+//   ./check_linux -verbose             # 显示失败项执行的远程命令及输出，并保存完整记录
+//   ./check_linux -watch -metrics-addr=:9105   # 持续监控并提供 Prometheus /metrics
+//   ./check_linux -max-workers=MDC1A=1,MDC2=2  # 调整各 MDC 上 pmupload 的最大并发数
+//   ./check_linux -batch               # 同主机的 Topic 合并为一次 pmupload 调用（需 pmupload 支持）
+//   ./check_linux -inventory=inventory.json -vehicle=V001   # 从车队清单读取各 MDC 的候选挂载目标
 
 package main
 
 import (
 	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
+	"math"
 	"net"
+	"net/http"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"regexp"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -21,6 +37,7 @@ import (
 	"unicode"
 
 	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
 )
 
 // ===== 固定配置 =====
@@ -37,7 +54,14 @@ const (
 	CONNECT_TIMEOUT   = 8 * time.Second
 	CMD_TIMEOUT       = 8 * time.Second
 	PMUPLOAD_TIMEOUT  = 20 * time.Second
+	PMUPLOAD_HZ_CMD   = "timeout 8s pmupload adstopic hz"
+	VEHICLE_STATE_CMD = "timeout 8s pmupload adstopic echo /vehicle_state -n 1" // 在 MDC1 上读取档位/刹车/点火状态
 	MOUNT_TIMEOUT_SEC = 8
+	MOUNT_CMD_TIMEOUT = (MOUNT_TIMEOUT_SEC + 5) * time.Second // 需大于远端 timeout，才能拿到其 124 退出码
+
+	SMB_PORT               = 445
+	NFS_PORT               = 2049
+	PORT_PROBE_TIMEOUT_SEC = 3
 
 	MDC1_IP = "192.168.30.41"
 	MDC2_IP = "192.168.30.143"
@@ -50,8 +74,97 @@ const (
 
 	MDC1_MAX_WORKERS = 2
 	MDC2_MAX_WORKERS = 4
+
+	WATCH_INTERVAL     = 60 * time.Second
+	WATCH_MIN_AVAIL_GB = 50.0
+)
+
+// 挂载类型
+const (
+	MOUNT_CIFS  = "cifs"  // Source: //NAS_IP/share
+	MOUNT_NFS   = "nfs"   // Source: NAS_IP:/export
+	MOUNT_LOCAL = "local" // Source: 本地块设备，如 /dev/nvme0n1p1
 )
 
+// MountTarget 描述一个可挂载到 MOUNT_POINT 的存储目标。
+// Type 为空时按 cifs 处理，Options 为空时使用该类型的默认选项。
+type MountTarget struct {
+	Type    string `json:"type"`
+	Source  string `json:"source"`
+	Options string `json:"options,omitempty"`
+}
+
+// 挂载配置：每台 MDC 按优先级列出候选挂载目标，首选不可用时依次尝试后备
+var MDC1_MOUNT_TARGETS = []MountTarget{
+	{Type: MOUNT_CIFS, Source: "//" + NAS_160 + "/nas"},
+	{Type: MOUNT_CIFS, Source: "//" + NAS_60 + "/nas"},
+}
+var MDC2_MOUNT_TARGETS = []MountTarget{
+	{Type: MOUNT_CIFS, Source: "//" + NAS_60 + "/nas"},
+	{Type: MOUNT_CIFS, Source: "//" + NAS_160 + "/nas"},
+}
+
+// vehicleID 车辆编号，用于指标标签和从清单中选车，由 -vehicle 或 CHECK_CAR_VEHICLE 指定
+var vehicleID = os.Getenv("CHECK_CAR_VEHICLE")
+
+// loadInventory 从车队清单（与 check_json 相同格式）中读取 hosts 连接配置（供 -jump 按别名引用跳板机），
+// 以及指定车辆两台 MDC 的候选挂载目标，覆盖上面的默认值；
+// 清单中只有一辆车时可不指定 vehicle，某台 MDC 未配置 mount_targets 时保留默认值
+func loadInventory(path, vehicle string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	type mdc struct {
+		Host         string        `json:"host"`
+		MountTargets []MountTarget `json:"mount_targets"`
+	}
+	var inv struct {
+		Hosts    map[string]HostConfig `json:"hosts"`
+		Vehicles []struct {
+			ID    string   `json:"id"`
+			Hosts []string `json:"hosts"`
+			MDC1  mdc      `json:"mdc1"`
+			MDC2  mdc      `json:"mdc2"`
+		} `json:"vehicles"`
+	}
+	if err := json.Unmarshal(data, &inv); err != nil {
+		return fmt.Errorf("解析清单 %s 失败: %v", path, err)
+	}
+	for name, hc := range inv.Hosts {
+		hostConfigs[name] = hc
+	}
+	for _, v := range inv.Vehicles {
+		if v.ID != vehicle && (vehicle != "" || len(inv.Vehicles) != 1) {
+			continue
+		}
+		for _, targets := range [][]MountTarget{v.MDC1.MountTargets, v.MDC2.MountTargets} {
+			for _, t := range targets {
+				if !validMountType(t.Type) || t.Source == "" {
+					return fmt.Errorf("车辆 %s 的挂载目标 %q 类型 %q 无效（可选 cifs/nfs/local）或 source 为空", v.ID, t.Source, t.Type)
+				}
+			}
+		}
+		// 本工具按固定地址连接主机，所选车辆的主机配置（proxy_jump、认证方式等）同时按地址登记
+		for _, name := range append(v.Hosts, v.MDC1.Host, v.MDC2.Host) {
+			if hc, ok := inv.Hosts[name]; ok {
+				hostConfigs[hc.Addr] = hc
+			}
+		}
+		if len(v.MDC1.MountTargets) > 0 {
+			MDC1_MOUNT_TARGETS = v.MDC1.MountTargets
+		}
+		if len(v.MDC2.MountTargets) > 0 {
+			MDC2_MOUNT_TARGETS = v.MDC2.MountTargets
+		}
+		return nil
+	}
+	if vehicle == "" {
+		return fmt.Errorf("清单 %s 中有多辆车，请用 -vehicle 指定", path)
+	}
+	return fmt.Errorf("清单 %s 中没有车辆 %s", path, vehicle)
+}
+
 // Topic 映射
 var MDC1_TOPIC_CMDS = []struct {
 	Name string
@@ -65,6 +178,12 @@ var MDC1_TOPIC_CMDS = []struct {
 	{"9. MDC1A 前向激光雷达", "timeout 8s pmupload adstopic hz /lidar_side_front"},
 }
 
+// TOPIC_REQUIRES 发布时依赖车辆状态的 Topic 及所需状态（多个可接受值用 | 分隔），
+// 这些 Topic 未发布时先读取车辆状态，不满足则标记为跳过（前置条件未满足），不计为失败
+var TOPIC_REQUIRES = map[string]map[string]string{
+	"/lidar_side_front": {"gear": "D", "brake": "on"},
+}
+
 var MDC2_TOPIC_CMDS = []struct {
 	Name string
 	Cmd  string
@@ -77,18 +196,21 @@ var MDC2_TOPIC_CMDS = []struct {
 
 // ANSI colors
 const (
-	GREEN = "\033[92m"
-	RED   = "\033[91m"
-	RESET = "\033[0m"
+	GREEN  = "\033[92m"
+	RED    = "\033[91m"
+	YELLOW = "\033[93m"
+	RESET  = "\033[0m"
 )
 
 var (
 	OK   = GREEN + "√" + RESET
 	FAIL = RED + "X" + RESET
+	SKIP = YELLOW + "-" + RESET // 车辆状态前置条件未满足，不计为失败
 )
 
 var ANSI_RE = regexp.MustCompile(`\x1b\[[0-9;]*m`)
 var PMUPLOAD_WINDOW_RE = regexp.MustCompile(`^\s*/\S+.*\s(\d+)\s*$`)
+var MOUNT_ERROR_RE = regexp.MustCompile(`mount error\((\d+)\)`)
 
 // Row 表示检测结果行
 type Row struct {
@@ -187,18 +309,159 @@ func readKey() string {
 }
 
 // ---------- SSH helpers ----------
+
+// proxyJump 跳板机，多跳用逗号分隔（同 ssh -J），由 -jump 或 CHECK_CAR_JUMP 指定
+var proxyJump = os.Getenv("CHECK_CAR_JUMP")
+
+// workerOverrides 按主机或 MDC 名称覆盖 pmupload 最大并发数，由 -max-workers 或 CHECK_CAR_MAX_WORKERS 指定
+var workerOverrides = map[string]int{}
+
+func init() {
+	if s := os.Getenv("CHECK_CAR_MAX_WORKERS"); s != "" {
+		if err := parseWorkerOverrides(s, workerOverrides); err != nil {
+			fmt.Fprintf(os.Stderr, "CHECK_CAR_MAX_WORKERS: %v\n", err)
+		}
+	}
+}
+
+// parseWorkerOverrides 解析 "MDC1A=1,192.168.30.143=2" 形式的并发配置，键不区分大小写
+func parseWorkerOverrides(s string, into map[string]int) error {
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		key, val, ok := strings.Cut(part, "=")
+		n, err := strconv.Atoi(strings.TrimSpace(val))
+		if !ok || err != nil || n <= 0 || strings.TrimSpace(key) == "" {
+			return fmt.Errorf("无效的并发配置 %q，格式为 主机或MDC名称=正整数", part)
+		}
+		into[strings.ToLower(strings.TrimSpace(key))] = n
+	}
+	return nil
+}
+
+// workersFor 返回主机上 pmupload 的最大并发数：-max-workers 中按主机、再按 MDC 名称匹配，否则取默认值
+func workersFor(host, name string, def int) int {
+	for _, key := range []string{host, name} {
+		if n, ok := workerOverrides[strings.ToLower(key)]; ok {
+			return n
+		}
+	}
+	return def
+}
+
+// HostConfig 一台主机（车内主机或跳板机）的 SSH 连接配置，与 check_json 清单中 hosts 的格式相同
+type HostConfig struct {
+	Addr         string `json:"addr"`
+	Port         int    `json:"port,omitempty"`
+	User         string `json:"user,omitempty"`          // 默认 USERNAME
+	Password     string `json:"password,omitempty"`      // user 为 USERNAME 时默认 PASSWORD，其他账号需单独配置
+	IdentityFile string `json:"identity_file,omitempty"` // 私钥文件，如 ~/.ssh/id_ed25519（不支持带口令的私钥）
+	Agent        bool   `json:"agent,omitempty"`         // 使用 ssh-agent（SSH_AUTH_SOCK）中的密钥
+	LocalAddr    string `json:"local_addr,omitempty"`    // 多网卡/VLAN 时指定本机出口地址
+	ProxyJump    string `json:"proxy_jump,omitempty"`    // 跳板机，多跳用逗号分隔（同 ssh -J），默认 -jump
+}
+
+// hostConfigs 由 -inventory 在检测开始前填充，之后只读
+var hostConfigs = map[string]HostConfig{}
+
+// resolveHost 返回主机的连接配置。name 可以是清单 hosts 中的别名，也可以是 [user@]addr[:port]
+func resolveHost(name string) HostConfig {
+	hc, ok := hostConfigs[name]
+	if !ok {
+		hc = HostConfig{Addr: name}
+		if i := strings.LastIndex(hc.Addr, "@"); i >= 0 {
+			hc.User, hc.Addr = hc.Addr[:i], hc.Addr[i+1:]
+		}
+		if h, p, err := net.SplitHostPort(hc.Addr); err == nil {
+			hc.Addr = h
+			hc.Port, _ = strconv.Atoi(p)
+		}
+	}
+	if hc.Port == 0 {
+		hc.Port = PORT
+	}
+	if hc.User == "" {
+		hc.User = USERNAME
+	}
+	if hc.Password == "" && hc.User == USERNAME {
+		hc.Password = PASSWORD
+	}
+	if !ok && hc.User != USERNAME {
+		// -jump=ops@bastion 这类未在清单中配置的非车内账号，不发送车内 root 密码，改用 ssh-agent
+		hc.Agent = true
+	}
+	if hc.ProxyJump == "" {
+		hc.ProxyJump = proxyJump
+	}
+	return hc
+}
+
+// endpoint 返回 user@addr:port，用于判断两跳是否为同一连接
+func (hc HostConfig) endpoint() string {
+	return hc.User + "@" + net.JoinHostPort(hc.Addr, strconv.Itoa(hc.Port))
+}
+
+// sshConnect 连接主机，按主机的 proxy_jump（默认 -jump）依次经过各跳；链路中出现目标主机自身（用户、地址和端口都相同）时
+// 截断在它之前（如检测车内网关本身时直连），跳板机自己的 proxy_jump 不再展开。返回的连接关闭后，跳板连接随之关闭。
 func sshConnect(host string) (*ssh.Client, error) {
+	target := resolveHost(host)
+
+	var via *ssh.Client
+	for _, s := range strings.Split(target.ProxyJump, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		hop := resolveHost(s)
+		if s == host || hop.endpoint() == target.endpoint() {
+			break
+		}
+		next, err := sshDial(via, hop)
+		if err != nil {
+			if via != nil {
+				via.Close()
+			}
+			return nil, fmt.Errorf("连接跳板机 %s 失败: %v", s, err)
+		}
+		via = next
+	}
+
+	client, err := sshDial(via, target)
+	if err != nil && via != nil {
+		via.Close()
+	}
+	return client, err
+}
+
+// sshDial 建立一跳 SSH 连接：via 为空时直接 TCP 连接，否则经 via 转发
+func sshDial(via *ssh.Client, hc HostConfig) (*ssh.Client, error) {
+	auth, closeAuth, err := sshAuthMethods(hc)
+	if err != nil {
+		return nil, err
+	}
+	defer closeAuth()
 	config := &ssh.ClientConfig{
-		User: USERNAME,
-		Auth: []ssh.AuthMethod{
-			ssh.Password(PASSWORD),
-		},
+		User:            hc.User,
+		Auth:            auth,
 		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
 		Timeout:         CONNECT_TIMEOUT,
 	}
 
-	addr := fmt.Sprintf("%s:%d", host, PORT)
-	conn, err := net.DialTimeout("tcp", addr, CONNECT_TIMEOUT)
+	addr := net.JoinHostPort(hc.Addr, strconv.Itoa(hc.Port))
+	var conn net.Conn
+	if via == nil {
+		dialer := net.Dialer{Timeout: CONNECT_TIMEOUT}
+		if hc.LocalAddr != "" {
+			dialer.LocalAddr = &net.TCPAddr{IP: net.ParseIP(hc.LocalAddr)}
+		}
+		conn, err = dialer.Dial("tcp", addr)
+	} else {
+		ctx, cancel := context.WithTimeout(context.Background(), CONNECT_TIMEOUT)
+		conn, err = via.DialContext(ctx, "tcp", addr)
+		cancel()
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -209,11 +472,57 @@ func sshConnect(host string) (*ssh.Client, error) {
 		return nil, err
 	}
 
-	return ssh.NewClient(c, chans, reqs), nil
+	client := ssh.NewClient(c, chans, reqs)
+	if via != nil {
+		go func() {
+			client.Wait()
+			via.Close()
+		}()
+	}
+	return client, nil
 }
 
-func trySSH(host string) bool {
-	client, err := sshConnect(host)
+// sshAuthMethods 返回一跳的认证方式，依次尝试私钥文件、ssh-agent 和密码。
+// 返回的 close 用于在握手结束后关闭与 ssh-agent 的连接。
+func sshAuthMethods(hc HostConfig) ([]ssh.AuthMethod, func(), error) {
+	var methods []ssh.AuthMethod
+	closeAuth := func() {}
+	if hc.IdentityFile != "" {
+		path := hc.IdentityFile
+		if rest, ok := strings.CutPrefix(path, "~/"); ok {
+			if home, err := os.UserHomeDir(); err == nil {
+				path = filepath.Join(home, rest)
+			}
+		}
+		key, err := os.ReadFile(path)
+		if err != nil {
+			return nil, closeAuth, fmt.Errorf("读取私钥失败: %v", err)
+		}
+		signer, err := ssh.ParsePrivateKey(key)
+		if err != nil {
+			return nil, closeAuth, fmt.Errorf("解析私钥 %s 失败: %v", hc.IdentityFile, err)
+		}
+		methods = append(methods, ssh.PublicKeys(signer))
+	}
+	if hc.Agent {
+		if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+			if conn, err := net.DialTimeout("unix", sock, CONNECT_TIMEOUT); err == nil {
+				methods = append(methods, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+				closeAuth = func() { conn.Close() }
+			}
+		}
+	}
+	if hc.Password != "" {
+		methods = append(methods, ssh.Password(hc.Password))
+	}
+	if len(methods) == 0 {
+		return nil, closeAuth, fmt.Errorf("%s@%s 没有可用的认证方式（请配置 password、identity_file，或设置 SSH_AUTH_SOCK 使用 ssh-agent）", hc.User, hc.Addr)
+	}
+	return methods, closeAuth, nil
+}
+
+func trySSH(host string, log *evidenceLog) bool {
+	client, err := dialHost(host, log, 1)
 	if err != nil {
 		return false
 	}
@@ -221,7 +530,183 @@ func trySSH(host string) bool {
 	return true
 }
 
-func execCmd(client *ssh.Client, cmd string, timeout time.Duration) (int, string, string, error) {
+// ---------- 命令记录 (-verbose) ----------
+
+// Evidence 检测过程中执行的一条远程命令（或一次 SSH 连接）的记录，敏感信息已脱敏
+type Evidence struct {
+	Host     string `json:"host"`
+	Command  string `json:"command"`
+	Start    string `json:"start"`
+	End      string `json:"end"`
+	ExitCode int    `json:"exit_code"`
+	Stdout   string `json:"stdout,omitempty"`
+	Stderr   string `json:"stderr,omitempty"`
+	Error    string `json:"error,omitempty"`
+	Attempt  int    `json:"attempt"`
+	PTY      bool   `json:"pty"` // 是否分配伪终端；本工具执行命令时从不请求 PTY，恒为 false
+}
+
+// evidenceLog 一个检测项的命令记录，可被多个 goroutine 并发追加；nil 表示不记录
+type evidenceLog struct {
+	mu    sync.Mutex
+	items []Evidence
+}
+
+func (l *evidenceLog) add(e Evidence) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	l.items = append(l.items, e)
+	l.mu.Unlock()
+}
+
+func (l *evidenceLog) list() []Evidence {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]Evidence(nil), l.items...)
+}
+
+// SECRET_KV_RE 匹配 password=xxx、token=xxx 以及 NAS_PASS=xxx、API_TOKEN=xxx 这类带前缀的变量
+var SECRET_KV_RE = regexp.MustCompile(`(?i)\b(\w*(?:pass|passwd|password|token|secret))=([^,\s'"]+)`)
+
+// redactSecrets 隐去命令和输出中的密码：key=value 形式的凭据以及已知的 SSH/NAS 密码
+func redactSecrets(s string) string {
+	s = SECRET_KV_RE.ReplaceAllString(s, "$1=***")
+	secrets := []string{PASSWORD, NAS_PASS}
+	for _, hc := range hostConfigs {
+		if hc.Password != "" {
+			secrets = append(secrets, hc.Password)
+		}
+	}
+	for _, secret := range secrets {
+		s = strings.ReplaceAll(s, secret, "***")
+	}
+	return s
+}
+
+// sshConn 一条到主机的 SSH 连接；经 execCmd 执行的命令都会记入 log
+type sshConn struct {
+	*ssh.Client
+	host    string
+	log     *evidenceLog
+	attempt int
+}
+
+// dialHost 连接主机并把连接结果记入 log；attempt 为该检测项的第几次尝试
+func dialHost(host string, log *evidenceLog, attempt int) (*sshConn, error) {
+	start := time.Now()
+	client, err := sshConnect(host)
+	e := Evidence{
+		Host:    host,
+		Command: "ssh " + host,
+		Start:   start.Format(time.RFC3339Nano),
+		End:     time.Now().Format(time.RFC3339Nano),
+		Attempt: attempt,
+	}
+	if err != nil {
+		e.ExitCode = -1
+		e.Error = redactSecrets(err.Error())
+		log.add(e)
+		return nil, err
+	}
+	log.add(e)
+	sshConnectSeconds.Store(host, time.Since(start).Seconds())
+	return &sshConn{Client: client, host: host, log: log, attempt: attempt}, nil
+}
+
+// sshConnectSeconds 各主机最近一次 SSH 连接建立耗时（秒），供持续监控的 /metrics 使用
+var sshConnectSeconds sync.Map
+
+// verbose 由 -verbose 开启：记录每个检测项执行的远程命令，检测后显示失败项的记录并保存完整记录
+var verbose bool
+
+var (
+	evidenceMu   sync.Mutex
+	evidenceLogs = map[string]*evidenceLog{}
+)
+
+// evidenceFor 为检测项开始一份新的命令记录；未开启 -verbose 时返回 nil（不记录）
+func evidenceFor(item string) *evidenceLog {
+	if !verbose {
+		return nil
+	}
+	l := &evidenceLog{}
+	evidenceMu.Lock()
+	evidenceLogs[item] = l
+	evidenceMu.Unlock()
+	return l
+}
+
+func formatEvidence(b *strings.Builder, e Evidence) {
+	fmt.Fprintf(b, "--- [%s] 第 %d 次 %s $ %s\n", e.Host, e.Attempt, e.Start, e.Command)
+	fmt.Fprintf(b, "    结束 %s  退出码 %d  PTY %v\n", e.End, e.ExitCode, e.PTY)
+	if e.Error != "" {
+		fmt.Fprintf(b, "    错误: %s\n", e.Error)
+	}
+	if e.Stdout != "" {
+		fmt.Fprintf(b, "[stdout]\n%s\n", strings.TrimRight(e.Stdout, "\n"))
+	}
+	if e.Stderr != "" {
+		fmt.Fprintf(b, "[stderr]\n%s\n", strings.TrimRight(e.Stderr, "\n"))
+	}
+}
+
+// showEvidence 在表格后显示失败项执行过的命令，并把全部检测项的记录保存到当前目录的 transcript 文件
+func showEvidence(rows []Row) {
+	if !verbose {
+		return
+	}
+	evidenceMu.Lock()
+	defer evidenceMu.Unlock()
+
+	var screen, file strings.Builder
+	for _, r := range rows {
+		var b strings.Builder
+		fmt.Fprintf(&b, "\n===== %s =====\n%s\n", r.Item, ANSI_RE.ReplaceAllString(r.Tip, ""))
+		for _, e := range evidenceLogs[r.Item].list() {
+			formatEvidence(&b, e)
+		}
+		file.WriteString(b.String())
+		if isFailStatus(r.Status) {
+			screen.WriteString(b.String())
+		}
+	}
+	fmt.Print(screen.String())
+
+	path := fmt.Sprintf("check_transcript_%s.log", time.Now().Format("20060102-150405"))
+	if err := os.WriteFile(path, []byte(file.String()), 0644); err != nil {
+		fmt.Printf("保存命令记录失败: %v\n", err)
+		return
+	}
+	fmt.Printf("\n完整命令记录已保存到 %s\n", path)
+}
+
+// execCmd 执行远程命令并记入连接的命令记录
+func execCmd(client *sshConn, cmd string, timeout time.Duration) (int, string, string, error) {
+	start := time.Now()
+	code, stdout, stderr, err := execSSH(client.Client, cmd, timeout)
+	e := Evidence{
+		Host:     client.host,
+		Command:  redactSecrets(cmd),
+		Start:    start.Format(time.RFC3339Nano),
+		End:      time.Now().Format(time.RFC3339Nano),
+		ExitCode: code,
+		Stdout:   redactSecrets(stdout),
+		Stderr:   redactSecrets(stderr),
+		Attempt:  client.attempt,
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	client.log.add(e)
+	return code, stdout, stderr, err
+}
+
+func execSSH(client *ssh.Client, cmd string, timeout time.Duration) (int, string, string, error) {
 	session, err := client.NewSession()
 	if err != nil {
 		return -1, "", "", err
@@ -285,33 +770,106 @@ func parseSizeToGB(sizeStr string) (float64, bool) {
 	return val * factors[unit], true
 }
 
-func dfFindMountAvail(dfOut, nasIP string) (bool, string, float64, bool) {
-	for _, line := range strings.Split(dfOut, "\n") {
-		if strings.Contains(line, nasIP) {
-			parts := strings.Fields(line)
-			if len(parts) >= 6 {
-				availStr := parts[3]
-				availGB, ok := parseSizeToGB(availStr)
-				return true, availStr, availGB, ok
+// dfFields 在 df 输出中查找该挂载目标挂在 MOUNT_POINT 上的行并返回各列，未挂载时返回 nil。
+// 文件系统列须与目标一致、挂载点列须为 MOUNT_POINT；源过长被 df 折成两行时先合并
+func dfFields(dfOut string, t MountTarget) []string {
+	lines := strings.Split(dfOut, "\n")
+	for i := 0; i < len(lines); i++ {
+		parts := strings.Fields(lines[i])
+		if len(parts) == 1 && i+1 < len(lines) {
+			if next := strings.Fields(lines[i+1]); len(next) == 5 {
+				parts = append(parts, next...)
+				i++
 			}
-			return true, "", 0, false
+		}
+		if len(parts) >= 6 && dfSourceMatches(parts[0], t) && parts[len(parts)-1] == MOUNT_POINT {
+			return parts
 		}
 	}
-	return false, "", 0, false
+	return nil
 }
 
-func buildMountCmd(nasIP string) string {
-	mountOpts := fmt.Sprintf("vers=2.0,username=%s,password=%s,cache=strict,"+
-		"uid=1000,forceuid,gid=1000,forcegid,"+
-		"file_mode=0755,dir_mode=0755,soft,nounix,noserverino,mapposix,"+
-		"rsize=65536,wsize=65536,bsize=1048576,echo_interval=60,actimeo=1",
-		NAS_USER, NAS_PASS)
+// dfFindMountAvail 在 df -h 输出中查找该挂载目标，返回 (已挂载, 可用容量原文, 可用容量GB, 解析成功)
+func dfFindMountAvail(dfOut string, t MountTarget) (bool, string, float64, bool) {
+	parts := dfFields(dfOut, t)
+	if parts == nil {
+		return false, "", 0, false
+	}
+	availStr := parts[3]
+	availGB, ok := parseSizeToGB(availStr)
+	return true, availStr, availGB, ok
+}
 
-	return fmt.Sprintf("mkdir -p %s; umount -l %s 2>/dev/null || true; timeout %ds mount -t cifs //%s/nas %s -o %s",
-		MOUNT_POINT, MOUNT_POINT, MOUNT_TIMEOUT_SEC, nasIP, MOUNT_POINT, mountOpts)
+// targetHost 返回网络存储的主机地址（cifs: //host/share，nfs: host:/export），本地盘返回空串
+func targetHost(t MountTarget) string {
+	switch t.Type {
+	case MOUNT_CIFS, "":
+		return strings.SplitN(strings.TrimPrefix(t.Source, "//"), "/", 2)[0]
+	case MOUNT_NFS:
+		return strings.SplitN(t.Source, ":", 2)[0]
+	}
+	return ""
 }
 
-func checkMountAlive(client *ssh.Client) bool {
+// dfSourceMatches 判断 df 的文件系统列是否就是该挂载目标：cifs 按 NAS 主机比较（共享名写法可能不同），
+// nfs 忽略末尾的 /，本地盘精确比较（/dev/nvme0n1p1 不匹配 /dev/nvme0n1p10）
+func dfSourceMatches(source string, t MountTarget) bool {
+	switch t.Type {
+	case MOUNT_CIFS, "":
+		return strings.HasPrefix(source, "//") && strings.EqualFold(targetHost(MountTarget{Source: source}), targetHost(t))
+	case MOUNT_NFS:
+		return strings.TrimRight(source, "/") == strings.TrimRight(t.Source, "/")
+	}
+	return source == t.Source
+}
+
+// validMountType 检查配置中的挂载类型，空串按 cifs 处理
+func validMountType(typ string) bool {
+	switch typ {
+	case "", MOUNT_CIFS, MOUNT_NFS, MOUNT_LOCAL:
+		return true
+	}
+	return false
+}
+
+func buildMountCmd(t MountTarget) string {
+	var mountCmd string
+	switch t.Type {
+	case MOUNT_NFS:
+		opts := t.Options
+		if opts == "" {
+			opts = "soft,timeo=50,retrans=2,nolock"
+		}
+		mountCmd = fmt.Sprintf("mount -t nfs %s %s -o %s", t.Source, MOUNT_POINT, opts)
+	case MOUNT_LOCAL:
+		mountCmd = fmt.Sprintf("mount %s %s", t.Source, MOUNT_POINT)
+		if t.Options != "" {
+			mountCmd += " -o " + t.Options
+		}
+	default:
+		opts := t.Options
+		if opts == "" {
+			opts = fmt.Sprintf("vers=2.0,username=%s,password=%s,cache=strict,"+
+				"uid=1000,forceuid,gid=1000,forcegid,"+
+				"file_mode=0755,dir_mode=0755,soft,nounix,noserverino,mapposix,"+
+				"rsize=65536,wsize=65536,bsize=1048576,echo_interval=60,actimeo=1",
+				NAS_USER, NAS_PASS)
+		}
+		mountCmd = fmt.Sprintf("mount -t cifs %s %s -o %s", t.Source, MOUNT_POINT, opts)
+	}
+
+	return fmt.Sprintf("mkdir -p %s; umount -l %s 2>/dev/null || true; timeout %ds %s",
+		MOUNT_POINT, MOUNT_POINT, MOUNT_TIMEOUT_SEC, mountCmd)
+}
+
+// checkMountAlive 确认挂载点可真实访问（避免 stale 假挂）；本地盘额外确认块设备仍然存在
+func checkMountAlive(client *sshConn, t MountTarget) bool {
+	if t.Type == MOUNT_LOCAL {
+		code0, _, _, _ := execCmd(client, fmt.Sprintf("test -b %s", t.Source), CMD_TIMEOUT)
+		if code0 != 0 {
+			return false
+		}
+	}
 	code1, _, _, _ := execCmd(client, fmt.Sprintf("ls %s >/dev/null 2>&1", MOUNT_POINT), CMD_TIMEOUT)
 	if code1 != 0 {
 		return false
@@ -320,70 +878,570 @@ func checkMountAlive(client *ssh.Client) bool {
 	return code2 == 0
 }
 
-func ensureMountAndGetDF(host, nasIP string) (bool, string, bool) {
-	didMountAttempt := false
-	client, err := sshConnect(host)
+// findMountUsers 列出占用挂载点的进程（PID + 命令名）。
+// 是否为挂载点按 /proc/mounts 判断，不访问挂载点本身；不是挂载点时不检查，否则 fuser -m 会把根文件系统上的所有进程都算进来。
+// fuser/lsof 超时（挂载已卡死）或无法完成检查时返回错误，调用方应拒绝重挂。
+func findMountUsers(client *sshConn) ([]string, error) {
+	cmd := fmt.Sprintf("awk '$2==\"%s\"{f=1} END{exit !f}' /proc/mounts; m=$?; [ $m -eq 1 ] && exit 0; [ $m -eq 0 ] || exit $m; "+
+		"if command -v fuser >/dev/null 2>&1; then pids=$(timeout %ds fuser -m %s 2>/dev/null); rc=$?; "+
+		"elif command -v lsof >/dev/null 2>&1; then pids=$(timeout %ds lsof -t %s 2>/dev/null); rc=$?; "+
+		"else exit 127; fi; "+
+		"[ $rc -le 1 ] || exit $rc; "+
+		"for p in $pids; do echo \"$p $(cat /proc/$p/comm 2>/dev/null)\"; done",
+		MOUNT_POINT, MOUNT_TIMEOUT_SEC, MOUNT_POINT, MOUNT_TIMEOUT_SEC, MOUNT_POINT)
+	code, out, _, err := execCmd(client, cmd, MOUNT_CMD_TIMEOUT)
+	switch {
+	case err != nil:
+		return nil, fmt.Errorf("检查命令未完成: %v", err)
+	case code == 124:
+		return nil, fmt.Errorf("fuser/lsof 超过 %ds 未返回，挂载可能已卡死", MOUNT_TIMEOUT_SEC)
+	case code == 127:
+		return nil, fmt.Errorf("MDC 上没有 fuser 或 lsof")
+	case code != 0:
+		return nil, fmt.Errorf("检查命令退出码 %d", code)
+	}
+
+	var users []string
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(out, "\n") {
+		parts := strings.Fields(line)
+		if len(parts) == 0 {
+			continue
+		}
+		pid := strings.TrimRight(parts[0], "cefFrm")
+		if _, err := strconv.Atoi(pid); err != nil || seen[pid] {
+			continue
+		}
+		seen[pid] = true
+		name := "?"
+		if len(parts) >= 2 {
+			name = parts[1]
+		}
+		users = append(users, fmt.Sprintf("PID %s (%s)", pid, name))
+	}
+	return users, nil
+}
+
+// PROBE_UNREACHABLE_RE 端口探测输出中表示端口确实不可达的错误
+var PROBE_UNREACHABLE_RE = regexp.MustCompile(`(?i)connection refused|no route to host|network is unreachable|host is unreachable|timed out`)
+
+// probeTarget 挂载前的预检：网络存储探测服务端口是否可达，本地盘确认块设备存在。
+// 返回 (通过, 已探测, 失败原因)；只有超时或明确的拒绝/不可达才算探测失败。MDC 上既没有 bash 也没有 nc、
+// 或 nc 不支持 -z（busybox 常见）等无法判断的情况不算已探测，直接交给 mount 判断。
+func probeTarget(client *sshConn, t MountTarget) (bool, bool, string) {
+	if t.Type == MOUNT_LOCAL {
+		code, _, _, err := execCmd(client, fmt.Sprintf("test -b %s", t.Source), CMD_TIMEOUT)
+		if err != nil {
+			return false, false, ""
+		}
+		return code == 0, true, fmt.Sprintf("块设备 %s 不存在，请检查硬盘是否插好", t.Source)
+	}
+
+	host := targetHost(t)
+	port := SMB_PORT
+	if t.Type == MOUNT_NFS {
+		port = NFS_PORT
+	}
+	cmd := fmt.Sprintf("if command -v bash >/dev/null 2>&1; then timeout %ds bash -c '</dev/tcp/%s/%d' 2>&1; "+
+		"elif command -v nc >/dev/null 2>&1; then timeout %ds nc -z -v -w %d %s %d 2>&1; "+
+		"else exit 127; fi",
+		PORT_PROBE_TIMEOUT_SEC, host, port, PORT_PROBE_TIMEOUT_SEC, PORT_PROBE_TIMEOUT_SEC, host, port)
+	code, out, _, err := execCmd(client, cmd, CMD_TIMEOUT)
+	switch {
+	case err != nil:
+		return false, false, ""
+	case code == 0:
+		return true, true, ""
+	case code == 124 || PROBE_UNREACHABLE_RE.MatchString(out):
+		return false, true, fmt.Sprintf("%s 的 %d 端口不可达，请确认 NAS 已上电并连好网线", host, port)
+	}
+	return false, false, ""
+}
+
+// mountErrorReason 解析 mount 的错误输出，给出对应原因和处理建议；无法识别时返回空串
+func mountErrorReason(t MountTarget, exitCode int, output string) string {
+	if m := MOUNT_ERROR_RE.FindStringSubmatch(output); m != nil {
+		switch m[1] {
+		case "13":
+			return "挂载被拒绝 error(13)，NAS 用户名或密码错误"
+		case "2":
+			return fmt.Sprintf("共享不存在 error(2)，请检查 NAS 共享 %s 是否存在", t.Source)
+		case "112", "113":
+			return fmt.Sprintf("NAS 主机不可达 error(%s)，请确认 NAS 已上电并连好网线", m[1])
+		case "110", "115":
+			return fmt.Sprintf("连接 NAS 超时 error(%s)，请确认 NAS 已上电并连好网线", m[1])
+		default:
+			return fmt.Sprintf("mount error(%s)，请换盘", m[1])
+		}
+	}
+
+	lower := strings.ToLower(output)
+	switch {
+	case strings.Contains(lower, "access denied"), strings.Contains(lower, "permission denied"):
+		return fmt.Sprintf("挂载被拒绝，请检查 %s 的访问权限", t.Source)
+	case strings.Contains(lower, "no such file or directory"), strings.Contains(lower, "does not exist"):
+		return fmt.Sprintf("%s 不存在，请检查配置或硬盘是否插好", t.Source)
+	case strings.Contains(lower, "wrong fs type"):
+		return fmt.Sprintf("%s 文件系统无法识别，请换盘", t.Source)
+	case strings.Contains(lower, "connection refused"), strings.Contains(lower, "timed out"):
+		return "连接 NAS 失败，请确认 NAS 已上电并连好网线"
+	}
+
+	if exitCode == 124 {
+		return fmt.Sprintf("挂载超时（>%ds），请确认 NAS 已上电并连好网线", MOUNT_TIMEOUT_SEC)
+	}
+	return ""
+}
+
+// mountStatus 描述一台 MDC 的挂载检查结果
+type mountStatus struct {
+	OK        bool
+	Target    MountTarget // 最终使用的挂载目标
+	AvailStr  string
+	Remounted bool
+	Attempts  int // 实际清理并重挂的候选数
+	BusyUsers []string
+	BusyErr   error    // 占用检查未能完成（如挂载卡死），此时同样拒绝重挂
+	Failures  []string // 各候选目标的失败原因（按尝试顺序）
+	ConnErr   error
+}
+
+// targetUsage 一个挂载目标的检查结果
+type targetUsage struct {
+	OK       bool
+	Full     bool // 已挂载且可读写，只是可用容量不足；重挂无济于事
+	AvailStr string
+	Reason   string
+	DfOut    string
+}
+
+// checkTargetUsable 判断挂载目标是否挂载在 MOUNT_POINT 上、容量足够且可读写
+func checkTargetUsable(client *sshConn, t MountTarget) targetUsage {
+	_, dfOut, _, _ := execCmd(client, "df -h", CMD_TIMEOUT)
+	u := targetUsage{DfOut: dfOut}
+	mounted, availStr, availGB, ok := dfFindMountAvail(dfOut, t)
+	u.AvailStr = availStr
+	switch {
+	case !mounted:
+		u.Reason = "挂载失败，请换盘"
+	case !ok || availStr == "":
+		u.Reason = "盘状态异常，请换盘"
+	case !checkMountAlive(client, t):
+		u.Reason = "挂载点无法读写，请换盘"
+	case availGB < MIN_AVAIL_GB:
+		u.Full = true
+		u.Reason = fmt.Sprintf("可用容量 %s（<800G），请换盘", availStr)
+	default:
+		u.OK = true
+	}
+	return u
+}
+
+// ensureMount 按优先级检查候选挂载目标，当前挂载的候选可用则直接使用；
+// 否则依次清理并重挂每个候选（每个只尝试一次，不循环重试），直到找到可用的目标。
+// 若挂载点正被进程占用（例如正在录制），拒绝重挂并返回占用进程列表。
+func ensureMount(host string, targets []MountTarget, log *evidenceLog) mountStatus {
+	var st mountStatus
+	client, err := dialHost(host, log, 1)
 	if err != nil {
-		return false, "", didMountAttempt
+		st.ConnErr = err
+		return st
 	}
 	defer client.Close()
 
-	_, dfOut, _, _ := execCmd(client, "df -h", CMD_TIMEOUT)
-	mounted, _, availGB, ok := dfFindMountAvail(dfOut, nasIP)
-	if mounted && ok && availGB >= MIN_AVAIL_GB && checkMountAlive(client) {
-		return true, dfOut, false
+	full := make(map[MountTarget]bool)
+	for _, t := range targets {
+		u := checkTargetUsable(client, t)
+		if u.OK {
+			st.OK, st.Target, st.AvailStr = true, t, u.AvailStr
+			return st
+		}
+		if u.Full {
+			full[t] = true
+			st.Failures = append(st.Failures, fmt.Sprintf("%s %s（已挂载，未重挂）", t.Source, u.Reason))
+		}
 	}
 
-	didMountAttempt = true
-	execCmd(client, buildMountCmd(nasIP), CMD_TIMEOUT)
+	users, err := findMountUsers(client)
+	if err != nil {
+		st.BusyErr = err
+		return st
+	}
+	if len(users) > 0 {
+		st.BusyUsers = users
+		return st
+	}
 
-	_, dfOut2, _, _ := execCmd(client, "df -h", CMD_TIMEOUT)
-	mounted2, _, availGB2, ok2 := dfFindMountAvail(dfOut2, nasIP)
-	okResult := mounted2 && ok2 && availGB2 >= MIN_AVAIL_GB && checkMountAlive(client)
-	return okResult, dfOut2, true
+	for _, t := range targets {
+		if full[t] {
+			continue
+		}
+		if passed, probed, reason := probeTarget(client, t); probed && !passed {
+			st.Failures = append(st.Failures, fmt.Sprintf("%s %s", t.Source, reason))
+			continue
+		}
+
+		st.Remounted = true
+		st.Attempts++
+		client.attempt++ // 每次重挂记为一次新的尝试
+		code, out, errOut, _ := execCmd(client, buildMountCmd(t), MOUNT_CMD_TIMEOUT)
+		u := checkTargetUsable(client, t)
+		if u.OK {
+			st.OK, st.Target, st.AvailStr = true, t, u.AvailStr
+			return st
+		}
+		reason := u.Reason
+		if detail := mountErrorReason(t, code, out+"\n"+errOut); detail != "" {
+			reason = detail
+		}
+		st.Failures = append(st.Failures, fmt.Sprintf("%s %s", t.Source, reason))
+	}
+	return st
 }
 
-func checkMountRowWithAutoMount(item, host, nasIP, missingTip string) (bool, Row) {
-	mounted, dfOut, _ := ensureMountAndGetDF(host, nasIP)
+// mountTip 根据挂载检查结果生成提醒文案
+func mountTip(st mountStatus, targets []MountTarget) string {
+	if st.ConnErr != nil {
+		return "SSH 连接失败，无法检查挂载，请上电或插上网线。"
+	}
+	if st.BusyErr != nil {
+		return fmt.Sprintf("无法确认挂载点 %s 是否被占用（%v），已拒绝自动重挂，请确认没有录制进程后手动处理。", MOUNT_POINT, st.BusyErr)
+	}
+	if len(st.BusyUsers) > 0 {
+		return fmt.Sprintf("挂载点 %s 正被占用，已拒绝自动重挂：%s，请先停止相关进程。", MOUNT_POINT, strings.Join(st.BusyUsers, ", "))
+	}
+	if !st.OK {
+		done := "未重挂"
+		if st.Attempts > 0 {
+			done = fmt.Sprintf("已自动清理并重挂 %d 个候选", st.Attempts)
+		}
+		return fmt.Sprintf("挂载失败或盘不可用（%s）：%s。", done, strings.Join(st.Failures, "；"))
+	}
+	if len(targets) > 0 && st.Target != targets[0] {
+		return fmt.Sprintf("首选 %s 不可用，已切换到备用 %s，可用容量 %s", targets[0].Source, st.Target.Source, st.AvailStr)
+	}
+	return fmt.Sprintf("%s 可用容量 %s", st.Target.Source, st.AvailStr)
+}
 
-	if !mounted {
-		return false, Row{item, FAIL, "挂载失败或盘不可用（已自动清理并重挂一次），请换盘。"}
+func checkMountRowWithAutoMount(item, host string, targets []MountTarget) (bool, Row) {
+	st := ensureMount(host, targets, evidenceFor(item))
+	if !st.OK {
+		return false, Row{item, FAIL, mountTip(st, targets)}
 	}
+	return true, Row{item, OK, mountTip(st, targets)}
+}
+
+// ---------- pmupload parsing ----------
 
-	m, availStr, availGB, ok := dfFindMountAvail(dfOut, nasIP)
-	if !m || !ok || availStr == "" {
-		return false, Row{item, FAIL, "盘状态异常，请换盘。"}
+// HzSample pmupload hz 报告中一个 topic 的一次采样。间隔统一换算为秒，未输出的字段为 0
+type HzSample struct {
+	Topic    string  `json:"topic"`
+	Rate     float64 `json:"rate_hz"`
+	MinDelta float64 `json:"min_delta_seconds,omitempty"`
+	MaxDelta float64 `json:"max_delta_seconds,omitempty"`
+	StdDev   float64 `json:"std_dev_seconds,omitempty"`
+	Window   int     `json:"window"`
+}
+
+// HzStats pmupload hz 报告的解析结果及各采样的汇总
+type HzStats struct {
+	Samples   []HzSample `json:"samples"`
+	MeanRate  float64    `json:"mean_rate_hz"`
+	MinDelta  float64    `json:"min_delta_seconds"`   // 各采样最小间隔中的最小值
+	MaxDelta  float64    `json:"max_delta_seconds"`   // 各采样最大间隔中的最大值
+	MaxStdDev float64    `json:"max_std_dev_seconds"` // 各采样间隔标准差中的最大值
+}
+
+// HZ_KV_RE 匹配 "average rate: 9.99"、"min=0.098s"、"std dev: 1.2ms"、"window: 50" 等键值
+var HZ_KV_RE = regexp.MustCompile(`(?i)\b(average[ _]rate|avg[ _]rate|rate|hz|min[ _]delta|min|max[ _]delta|max|std[ _]?dev|std|window|windows)\s*[:=]\s*([-+]?[0-9]*\.?[0-9]+(?:[eE][-+]?[0-9]+)?)\s*(ms|us|s|hz)?\b`)
+
+// HZ_UNIT_SUFFIX_RE 表头中列名后的单位，如 rate(hz)、min(s)
+var HZ_UNIT_SUFFIX_RE = regexp.MustCompile(`\([^)]*\)`)
+
+// HZ_NUM_RE 匹配表格/位置格式中的数值（可带单位）
+var HZ_NUM_RE = regexp.MustCompile(`(?i)^([-+]?[0-9]*\.?[0-9]+(?:[eE][-+]?[0-9]+)?)(ms|us|s|hz)?$`)
+
+// hzField 把各种写法的字段名归一为 rate / min / max / std / window，无法识别时返回空
+func hzField(key string) string {
+	key = strings.ToLower(strings.NewReplacer(" ", "", "_", "", "-", "").Replace(key))
+	switch key {
+	case "averagerate", "avgrate", "rate", "hz":
+		return "rate"
+	case "mindelta", "min":
+		return "min"
+	case "maxdelta", "max":
+		return "max"
+	case "stddev", "std":
+		return "std"
+	case "window", "windows", "count":
+		return "window"
 	}
-	if availGB < MIN_AVAIL_GB {
-		return false, Row{item, FAIL, fmt.Sprintf("可用容量 %s（<800G），请换盘。", availStr)}
+	return ""
+}
+
+// hzSeconds 按单位把间隔换算为秒，无单位时按秒
+func hzSeconds(v float64, unit string) float64 {
+	switch strings.ToLower(unit) {
+	case "ms":
+		return v / 1e3
+	case "us":
+		return v / 1e6
 	}
+	return v
+}
 
-	return true, Row{item, OK, fmt.Sprintf("可用容量 %s", availStr)}
+// set 写入一个字段，返回是否识别
+func (s *HzSample) set(field string, v float64, unit string) bool {
+	switch field {
+	case "rate":
+		s.Rate = v
+	case "min":
+		s.MinDelta = hzSeconds(v, unit)
+	case "max":
+		s.MaxDelta = hzSeconds(v, unit)
+	case "std":
+		s.StdDev = hzSeconds(v, unit)
+	case "window":
+		s.Window = int(v)
+	default:
+		return false
+	}
+	return true
 }
 
-// ---------- pmupload parsing ----------
-func parsePmuploadWindows(text string) []int {
-	var windows []int
-	for _, line := range strings.Split(text, "\n") {
-		line = strings.TrimSpace(line)
-		if line == "" || !strings.HasPrefix(strings.TrimLeft(line, " \t"), "/") {
+// parseHzKV 解析一段文本中的键值字段，返回是否解析到任何字段以及是否包含 window
+func parseHzKV(s *HzSample, text string) (found, hasWindow bool) {
+	for _, m := range HZ_KV_RE.FindAllStringSubmatch(text, -1) {
+		v, err := strconv.ParseFloat(m[2], 64)
+		if err != nil {
 			continue
 		}
+		field := hzField(m[1])
+		if s.set(field, v, m[3]) {
+			found = true
+			hasWindow = hasWindow || field == "window"
+		}
+	}
+	return found, hasWindow
+}
+
+// parseHzHeader 识别表头行（含 window 列），返回各列对应的字段
+func parseHzHeader(line string) []string {
+	norm := strings.ToLower(line)
+	for _, pair := range [][2]string{{"average rate", "average_rate"}, {"avg rate", "avg_rate"}, {"min delta", "min_delta"},
+		{"max delta", "max_delta"}, {"std dev", "std_dev"}} {
+		norm = strings.ReplaceAll(norm, pair[0], pair[1])
+	}
+	cols := strings.Fields(HZ_UNIT_SUFFIX_RE.ReplaceAllString(norm, ""))
+	hasWindow := false
+	fields := make([]string, len(cols))
+	for i, c := range cols {
+		fields[i] = hzField(strings.Trim(c, "[]:|"))
+		hasWindow = hasWindow || fields[i] == "window"
+	}
+	if !hasWindow {
+		return nil
+	}
+	return fields
+}
+
+// parsePmuploadHz 解析 pmupload hz 的输出，兼容以下几种格式：
+//   - 单行键值：/topic average rate: 10.0 min: 0.098s max: 0.102s std dev: 0.0012s window: 50
+//   - 多行块：topic 独占一行，随后几行为键值（同 rostopic hz）
+//   - 表格：表头含 topic/rate/min/max/std dev/window 列，数据行以 topic 开头
+//   - 位置格式：/topic 后跟 rate min max std window 五个数，或只以 window 结尾（旧版本只解析这一种）
+//
+// 只有解析到 window 的采样才会保留，与旧版本按 window 判断 Topic 是否发布的逻辑一致。
+func parsePmuploadHz(text string) HzStats {
+	var samples []HzSample
+	var header []string
+	var pending *HzSample // 多行块中等待 window 的采样
+
+	flush := func() {
+		pending = nil
+	}
+
+	for _, raw := range strings.Split(text, "\n") {
+		line := strings.TrimSpace(strings.TrimRight(raw, "\r"))
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "/") {
+			if pending != nil {
+				if _, hasWindow := parseHzKV(pending, line); hasWindow {
+					samples = append(samples, *pending)
+					flush()
+				}
+				continue
+			}
+			if h := parseHzHeader(line); h != nil && !HZ_KV_RE.MatchString(line) {
+				header = h
+			}
+			continue
+		}
+
+		flush()
+		fields := strings.Fields(line)
+		s := HzSample{Topic: fields[0]}
+		rest := strings.TrimSpace(strings.TrimPrefix(line, fields[0]))
+		if rest == "" {
+			pending = &s
+			continue
+		}
+
+		// 有键值时只认 window 键，不按旧格式取行尾整数（如 "rate: 10" 的 10 是频率）；
+		// 没有 window 的键值行可能是多行块的开头，等后续行补上
+		if found, hasWindow := parseHzKV(&s, rest); found {
+			if hasWindow {
+				samples = append(samples, s)
+			} else {
+				pending = &s
+			}
+			continue
+		}
+
+		// 表格 / 位置格式：取出各列数值
+		var nums []float64
+		var units []string
+		for _, f := range fields[1:] {
+			if m := HZ_NUM_RE.FindStringSubmatch(strings.TrimSuffix(f, ",")); m != nil {
+				v, _ := strconv.ParseFloat(m[1], 64)
+				nums = append(nums, v)
+				units = append(units, m[2])
+			}
+		}
 		m := PMUPLOAD_WINDOW_RE.FindStringSubmatch(line)
-		if m != nil {
-			if val, err := strconv.Atoi(m[1]); err == nil {
-				windows = append(windows, val)
+		switch {
+		case len(header) == len(fields) && len(nums) == len(fields)-1:
+			for i, v := range nums {
+				s.set(header[i+1], v, units[i])
+			}
+			samples = append(samples, s)
+		case m != nil:
+			s.Window, _ = strconv.Atoi(m[1])
+			if len(nums) == 5 {
+				s.Rate = nums[0]
+				s.MinDelta = hzSeconds(nums[1], units[1])
+				s.MaxDelta = hzSeconds(nums[2], units[2])
+				s.StdDev = hzSeconds(nums[3], units[3])
+			} else if len(nums) == 2 {
+				s.Rate = nums[0]
 			}
+			samples = append(samples, s)
 		}
 	}
+
+	return summarizeHz(samples)
+}
+
+// summarizeHz 汇总各采样；窗口为 0 的采样不参与频率和间隔统计
+func summarizeHz(samples []HzSample) HzStats {
+	stats := HzStats{Samples: samples}
+	if stats.Samples == nil {
+		stats.Samples = []HzSample{}
+	}
+	n := 0
+	for _, s := range samples {
+		if s.Window == 0 {
+			continue
+		}
+		n++
+		stats.MeanRate += s.Rate
+		if s.MinDelta > 0 && (stats.MinDelta == 0 || s.MinDelta < stats.MinDelta) {
+			stats.MinDelta = s.MinDelta
+		}
+		stats.MaxDelta = math.Max(stats.MaxDelta, s.MaxDelta)
+		stats.MaxStdDev = math.Max(stats.MaxStdDev, s.StdDev)
+	}
+	if n > 0 {
+		stats.MeanRate /= float64(n)
+	}
+	return stats
+}
+
+// windows 返回各采样的 window，用于原有的 windows 判定和历史趋势
+func (h HzStats) windows() []int {
+	var windows []int
+	for _, s := range h.Samples {
+		windows = append(windows, s.Window)
+	}
 	return windows
 }
 
-func runPmuploadCheck(host, itemName, cmd string) (string, Row, bool) {
-	runOnce := func() []int {
-		client, err := sshConnect(host)
+// hasTiming 报告 pmupload 输出中是否带有频率或消息间隔字段（只有 window 的旧版本输出为 false）
+func (h HzStats) hasTiming() bool {
+	for _, s := range h.Samples {
+		if s.Rate > 0 || s.MaxDelta > 0 || s.StdDev > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// parsePmuploadWindows 返回 hz 报告中各采样的 window
+func parsePmuploadWindows(text string) []int {
+	return parsePmuploadHz(text).windows()
+}
+
+// topicMetric 一个 Topic 检测项最近一次的采样结果和耗时，供持续监控的 /metrics 使用
+type topicMetric struct {
+	Hz       HzStats
+	Duration float64
+}
+
+// topicMetrics 检测项名称 -> topicMetric
+var topicMetrics sync.Map
+
+// noSampleReason 没有任何采样时判断出的原因，决定提示中列出的可能原因
+type noSampleReason int
+
+const (
+	NO_SAMPLE_SILENT     noSampleReason = iota // 连接成功但 pmupload 没有任何输出，可能是并发过高
+	NO_SAMPLE_ERROR                            // pmupload 有输出（如报错）但没有采样
+	NO_SAMPLE_SSH_FAILED                       // SSH 连接失败
+	NO_SAMPLE_SERIAL                           // 串行重跑仍没有任何输出，已排除并发原因
+)
+
+func (n noSampleReason) hint() string {
+	switch n {
+	case NO_SAMPLE_ERROR:
+		return "Topic未发布/跑错IP"
+	case NO_SAMPLE_SSH_FAILED:
+		return "SSH 连接失败"
+	case NO_SAMPLE_SERIAL:
+		return "串行重跑仍无输出，可能Topic未发布/跑错IP"
+	}
+	return "可能并发过高/Topic未发布/跑错IP"
+}
+
+// pmuploadCheck 一个 Topic 检测项。串行重跑时再次调用 run，执行次数接着编号，命令记录追加在前一次之后
+type pmuploadCheck struct {
+	host, name, cmd string
+	log             *evidenceLog
+	attempt         int
+	serial          bool // 本次 run 是串行重跑
+	starved         bool // 最近一次 run 连接成功但 pmupload 没有任何输出，可能是并发过高
+}
+
+func newPmuploadCheck(host, name, cmd string) *pmuploadCheck {
+	return &pmuploadCheck{host: host, name: name, cmd: cmd, log: evidenceFor(name)}
+}
+
+func (c *pmuploadCheck) run() (Row, bool) {
+	host, itemName, cmd, log := c.host, c.name, c.cmd, c.log
+	start := time.Now()
+	var stats HzStats
+	defer func() {
+		topicMetrics.Store(itemName, topicMetric{Hz: stats, Duration: time.Since(start).Seconds()})
+	}()
+	c.starved = false
+	var dialFailed, hasOutput bool // 最后一次尝试是否连接失败、pmupload 是否有输出
+	runOnce := func() HzStats {
+		c.attempt++
+		client, err := dialHost(host, log, c.attempt)
+		dialFailed, hasOutput = err != nil, false
 		if err != nil {
-			return nil
+			return HzStats{}
 		}
 		defer client.Close()
 
@@ -393,37 +1451,55 @@ func runPmuploadCheck(host, itemName, cmd string) (string, Row, bool) {
 			merged += "\n"
 		}
 		merged += errOut
-		if strings.TrimSpace(merged) == "" {
-			return nil
+		hasOutput = strings.TrimSpace(merged) != ""
+		if !hasOutput {
+			return HzStats{}
 		}
-		return parsePmuploadWindows(merged)
+		return parsePmuploadHz(merged)
 	}
 
-	windows := runOnce()
+	stats = runOnce()
+	windows := stats.windows()
 	if len(windows) == 0 || allZero(windows) {
-		windows = runOnce()
+		stats = runOnce()
+		windows = stats.windows()
 	}
 
 	tipList := fmt.Sprintf("windows=%v", windows)
 
-	addDriveTipPrefix := func(tip string) string {
-		if strings.Contains(cmd, "/lidar_side_front") {
-			return "请驾驶员挂D档并踩住刹车，" + tip
+	// failRow 生成失败行；Topic 声明的车辆状态不满足时改为跳过行，不计为失败
+	failRow := func(tip string) (Row, bool) {
+		if requires := TOPIC_REQUIRES[topicOfCmd(cmd)]; len(requires) > 0 {
+			note, unmet := preconditionTip(requires)
+			if unmet {
+				return Row{itemName, SKIP, note + tip}, true
+			}
+			tip = note + tip
 		}
-		return tip
+		return Row{itemName, FAIL, tip}, false
 	}
 
 	if len(windows) == 0 {
-		tip := fmt.Sprintf("可能并发过高/Topic未发布/跑错IP | %s | %s", cmd, tipList)
-		return itemName, Row{itemName, FAIL, addDriveTipPrefix(tip)}, false
+		// 只有连接成功且没有任何输出时才可能是并发过高，串行重跑据此判断
+		reason := NO_SAMPLE_SILENT
+		switch {
+		case dialFailed:
+			reason = NO_SAMPLE_SSH_FAILED
+		case hasOutput:
+			reason = NO_SAMPLE_ERROR
+		case c.serial:
+			reason = NO_SAMPLE_SERIAL
+		}
+		row, ok := failRow(fmt.Sprintf("%s | %s | %s", reason.hint(), cmd, tipList))
+		c.starved = reason == NO_SAMPLE_SILENT && !ok
+		return row, ok
 	}
 
 	if hasZero(windows) {
-		tip := fmt.Sprintf("%s | %s", cmd, tipList)
-		return itemName, Row{itemName, FAIL, addDriveTipPrefix(tip)}, false
+		return failRow(fmt.Sprintf("%s | %s", cmd, tipList))
 	}
 
-	return itemName, Row{itemName, OK, tipList}, true
+	return Row{itemName, OK, tipList}, true
 }
 
 func allZero(arr []int) bool {
@@ -460,26 +1536,65 @@ func runPmuploadGroup(host string, items []struct{ Name, Cmd string }, maxWorker
 	okMap := make(map[string]bool)
 	var mu sync.Mutex
 
+	batched := make(map[string]bool)
+	if batchEnabled {
+		for name, row := range runPmuploadBatch(host, items) {
+			rowMap[name] = row
+			okMap[name] = true
+			batched[name] = true
+		}
+	}
+
 	sem := make(chan struct{}, maxWorkers)
 	var wg sync.WaitGroup
+	checks := make(map[string]*pmuploadCheck)
 
 	for _, item := range items {
+		if okMap[item.Name] {
+			continue
+		}
+		c := newPmuploadCheck(host, item.Name, item.Cmd)
+		checks[item.Name] = c
 		wg.Add(1)
-		go func(name, cmd string) {
+		go func(c *pmuploadCheck) {
 			defer wg.Done()
 			sem <- struct{}{}
 			defer func() { <-sem }()
 
-			n, row, ok := runPmuploadCheck(host, name, cmd)
+			row, ok := c.run()
 			mu.Lock()
-			rowMap[n] = row
-			okMap[n] = ok
+			rowMap[c.name] = row
+			okMap[c.name] = ok
 			mu.Unlock()
-		}(item.Name, item.Cmd)
+		}(c)
 	}
 
 	wg.Wait()
 
+	// 同组其他 topic 并发时能通过，而某项连接成功却没有任何输出，可能是并发过高导致的，逐个串行重跑确认；
+	// 全组都失败（车辆或 pmupload 本身有问题）、连接失败或 pmupload 有输出时不重跑；跳过项不算通过
+	succeeded := 0
+	for _, item := range items {
+		if rowMap[item.Name].Status == OK && !batched[item.Name] {
+			succeeded++
+		}
+	}
+	if maxWorkers > 1 && succeeded > 0 {
+		for _, item := range items {
+			c := checks[item.Name]
+			if c == nil || !c.starved {
+				continue
+			}
+			c.serial = true
+			row, ok := c.run()
+			if ok {
+				row.Tip += fmt.Sprintf(" | 并发 %d 时无输出、串行重跑通过，建议调低 -max-workers", maxWorkers)
+			}
+			rowMap[item.Name] = row
+			okMap[item.Name] = ok
+		}
+	}
+
 	rows := make([]Row, len(orderedNames))
 	allOK := true
 	for i, name := range orderedNames {
@@ -492,16 +1607,223 @@ func runPmuploadGroup(host string, items []struct{ Name, Cmd string }, maxWorker
 	return rows, allOK
 }
 
+// ---------- 车辆状态前置条件 ----------
+
+// VEHICLE_STATE_LINE_RE 匹配 gear: D、brake=1、ignition: "ON" 形式的行
+var VEHICLE_STATE_LINE_RE = regexp.MustCompile(`^\s*([A-Za-z_][\w.]*)\s*[:=]\s*"?([^"\s,]+)"?`)
+
+// normalizeStateValue 归一化状态值：档位去掉 GEAR_ 前缀，其余状态的 1/true/pressed 等统一为 ON，0/false/released 等统一为 OFF
+func normalizeStateValue(name, v string) string {
+	v = strings.ToUpper(strings.TrimSpace(v))
+	if name == "gear" {
+		return strings.TrimPrefix(v, "GEAR_")
+	}
+	switch v {
+	case "1", "TRUE", "ON", "YES", "PRESSED", "APPLIED":
+		return "ON"
+	case "0", "FALSE", "OFF", "NO", "RELEASED":
+		return "OFF"
+	}
+	return v
+}
+
+// parseVehicleState 从命令输出中取出同名字段（不区分大小写，也匹配 a.b.gear 的最后一段），同名字段取第一次出现的值
+func parseVehicleState(text string, names []string) map[string]string {
+	want := make(map[string]bool)
+	for _, name := range names {
+		want[strings.ToLower(name)] = true
+	}
+	values := make(map[string]string)
+	for _, line := range strings.Split(text, "\n") {
+		m := VEHICLE_STATE_LINE_RE.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		key := strings.ToLower(m[1])
+		if i := strings.LastIndex(key, "."); i >= 0 {
+			key = key[i+1:]
+		}
+		if _, seen := values[key]; want[key] && !seen {
+			values[key] = normalizeStateValue(key, m[2])
+		}
+	}
+	return values
+}
+
+// 车辆状态在一次检测（或监控的一轮）内只读取一次，由各 Topic 共享；resetVehicleState 在每次检测开始时清空
+var (
+	vehicleStateMu     sync.Mutex
+	vehicleStateRead   bool
+	vehicleStateValues map[string]string
+)
+
+func resetVehicleState() {
+	vehicleStateMu.Lock()
+	defer vehicleStateMu.Unlock()
+	vehicleStateRead, vehicleStateValues = false, nil
+}
+
+// readVehicleState 读取（或返回本次已读取的）TOPIC_REQUIRES 中用到的全部车辆状态，读取失败时返回空
+func readVehicleState() map[string]string {
+	vehicleStateMu.Lock()
+	defer vehicleStateMu.Unlock()
+	if vehicleStateRead {
+		return vehicleStateValues
+	}
+	vehicleStateRead = true
+
+	seen := make(map[string]bool)
+	var names []string
+	for _, requires := range TOPIC_REQUIRES {
+		for name := range requires {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+
+	client, err := dialHost(MDC1_IP, evidenceFor("车辆状态"), 1)
+	if err != nil {
+		return nil
+	}
+	defer client.Close()
+	_, out, errOut, _ := execCmd(client, VEHICLE_STATE_CMD, PMUPLOAD_TIMEOUT)
+	vehicleStateValues = parseVehicleState(out+"\n"+errOut, names)
+	return vehicleStateValues
+}
+
+// preconditionTip 核对 Topic 的前置条件，返回加在提示前的说明，以及车辆状态是否不满足
+func preconditionTip(requires map[string]string) (string, bool) {
+	var names, wants []string
+	for name := range requires {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		wants = append(wants, name+"="+requires[name])
+	}
+	want := strings.Join(wants, " ")
+
+	values := readVehicleState()
+	if len(values) == 0 {
+		return fmt.Sprintf("车辆状态读取失败，该 Topic 要求 %s | ", want), false
+	}
+
+	var unmet []string
+	for _, name := range names {
+		got, ok := values[name]
+		if !ok {
+			unmet = append(unmet, name+"=未知")
+			continue
+		}
+		matched := false
+		for _, w := range strings.Split(requires[name], "|") {
+			if normalizeStateValue(name, w) == got {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			unmet = append(unmet, name+"="+got)
+		}
+	}
+	if len(unmet) > 0 {
+		return fmt.Sprintf("precondition not met: %s（要求 %s），请驾驶员调整车辆状态后复检 | ", strings.Join(unmet, " "), want), true
+	}
+	return fmt.Sprintf("车辆状态满足 %s，非车辆状态原因 | ", want), false
+}
+
+// batchEnabled 为 true 时同主机的 Topic 合并为一次 pmupload 调用（-batch）。默认关闭：
+// 并非所有 MDC 上的 pmupload 都支持一次监听多个 topic，确认支持的车辆再开启
+var batchEnabled bool
+
+// batchUnsupported 记录不支持一次监听多个 topic 的主机，之后的检测直接逐个执行
+var batchUnsupported sync.Map
+
+// topicOfCmd 取命令中最后一个以 / 开头的参数作为 topic 名
+func topicOfCmd(cmd string) string {
+	fields := strings.Fields(cmd)
+	for i := len(fields) - 1; i >= 0; i-- {
+		if strings.HasPrefix(fields[i], "/") {
+			return fields[i]
+		}
+	}
+	return ""
+}
+
+// runPmuploadBatch 在一次 pmupload 中同时监听主机上使用标准 hz 命令的 topic，按 topic 拆分输出后判定。
+// 只返回通过的项；其余项由调用方逐个单独执行，失败结论始终来自单独执行。
+// 输出中没有第一个以外 topic 的采样（包括没有任何输出）时认为 pmupload 不支持多 topic，本进程不再对该主机合并执行。
+func runPmuploadBatch(host string, items []struct{ Name, Cmd string }) map[string]Row {
+	if _, unsupported := batchUnsupported.Load(host); unsupported {
+		return nil
+	}
+	var topics []string
+	names := make(map[string]string)
+	for _, item := range items {
+		t := topicOfCmd(item.Cmd)
+		if t == "" || item.Cmd != PMUPLOAD_HZ_CMD+" "+t || names[t] != "" {
+			continue
+		}
+		names[t] = item.Name
+		topics = append(topics, t)
+	}
+	if len(topics) < 2 {
+		return nil
+	}
+
+	start := time.Now()
+	client, err := dialHost(host, evidenceFor(host+" 合并 pmupload"), 1)
+	if err != nil {
+		return nil
+	}
+	defer client.Close()
+	_, out, errOut, _ := execCmd(client, PMUPLOAD_HZ_CMD+" "+strings.Join(topics, " "), PMUPLOAD_TIMEOUT)
+	merged := strings.TrimSpace(out + "\n" + errOut)
+
+	byTopic := make(map[string][]HzSample)
+	for _, s := range parsePmuploadHz(merged).Samples {
+		byTopic[s.Topic] = append(byTopic[s.Topic], s)
+	}
+	others := 0
+	for _, t := range topics[1:] {
+		others += len(byTopic[t])
+	}
+	if others == 0 {
+		batchUnsupported.Store(host, true)
+		return nil
+	}
+
+	rows := make(map[string]Row)
+	for _, t := range topics {
+		stats := summarizeHz(byTopic[t])
+		windows := stats.windows()
+		if len(windows) == 0 || hasZero(windows) {
+			continue
+		}
+		topicMetrics.Store(names[t], topicMetric{Hz: stats, Duration: time.Since(start).Seconds()})
+		rows[names[t]] = Row{names[t], OK, fmt.Sprintf("windows=%v（合并执行）", windows)}
+	}
+	return rows
+}
+
 // ---------- failed-only (X) ----------
 func isFailStatus(status string) bool {
 	cleaned := ANSI_RE.ReplaceAllString(status, "")
 	return strings.Contains(cleaned, "X")
 }
 
+func isSkipStatus(status string) bool {
+	return status == SKIP
+}
+
+// filterFailedItems 返回需要复检的项：失败项和前置条件未满足而跳过的项
 func filterFailedItems(rows []Row) map[string]bool {
 	failed := make(map[string]bool)
 	for _, r := range rows {
-		if isFailStatus(r.Status) {
+		if isFailStatus(r.Status) || isSkipStatus(r.Status) {
 			failed[r.Item] = true
 		}
 	}
@@ -510,11 +1832,13 @@ func filterFailedItems(rows []Row) map[string]bool {
 
 func runFullCheck() (bool, []Row) {
 	fmt.Println("开始检测...预计一分钟。")
+	resetVehicleState()
 	var rows []Row
 
 	carOK := true
+	carLog := evidenceFor("1. 车机状态")
 	for _, h := range HOSTS {
-		if !trySSH(h) {
+		if !trySSH(h, carLog) {
 			carOK = false
 			break
 		}
@@ -528,20 +1852,20 @@ func runFullCheck() (bool, []Row) {
 
 	mdc1OK, row2 := checkMountRowWithAutoMount(
 		fmt.Sprintf("2. %s MDC1A", MDC1_IP),
-		MDC1_IP, NAS_160, "未检测到 160 盘。",
+		MDC1_IP, MDC1_MOUNT_TARGETS,
 	)
 	rows = append(rows, row2)
 
 	mdc2OK, row3 := checkMountRowWithAutoMount(
 		fmt.Sprintf("3. %s MDC2", MDC2_IP),
-		MDC2_IP, NAS_60, "未检测到 60 盘。",
+		MDC2_IP, MDC2_MOUNT_TARGETS,
 	)
 	rows = append(rows, row3)
 
-	mdc1Rows, mdc1TopicsOK := runPmuploadGroup(MDC1_IP, MDC1_TOPIC_CMDS, MDC1_MAX_WORKERS)
+	mdc1Rows, mdc1TopicsOK := runPmuploadGroup(MDC1_IP, MDC1_TOPIC_CMDS, workersFor(MDC1_IP, "MDC1A", MDC1_MAX_WORKERS))
 	rows = append(rows, mdc1Rows...)
 
-	mdc2Rows, mdc2TopicsOK := runPmuploadGroup(MDC2_IP, MDC2_TOPIC_CMDS, MDC2_MAX_WORKERS)
+	mdc2Rows, mdc2TopicsOK := runPmuploadGroup(MDC2_IP, MDC2_TOPIC_CMDS, workersFor(MDC2_IP, "MDC2", MDC2_MAX_WORKERS))
 	rows = append(rows, mdc2Rows...)
 
 	allSuccess := carOK && mdc1OK && mdc2OK && mdc1TopicsOK && mdc2TopicsOK
@@ -553,12 +1877,14 @@ func runFailedOnlyCheck(prevRows []Row) (bool, []Row) {
 	if len(failed) == 0 {
 		return true, nil
 	}
+	resetVehicleState()
 
 	if failed["1. 车机状态"] {
 		var rows []Row
 		carOK := true
+		carLog := evidenceFor("1. 车机状态")
 		for _, h := range HOSTS {
-			if !trySSH(h) {
+			if !trySSH(h, carLog) {
 				carOK = false
 				break
 			}
@@ -576,14 +1902,14 @@ func runFailedOnlyCheck(prevRows []Row) (bool, []Row) {
 
 	item2 := fmt.Sprintf("2. %s MDC1A", MDC1_IP)
 	if failed[item2] {
-		ok2, row2 := checkMountRowWithAutoMount(item2, MDC1_IP, NAS_160, "未检测到 160 盘。")
+		ok2, row2 := checkMountRowWithAutoMount(item2, MDC1_IP, MDC1_MOUNT_TARGETS)
 		rows = append(rows, row2)
 		allOK = allOK && ok2
 	}
 
 	item3 := fmt.Sprintf("3. %s MDC2", MDC2_IP)
 	if failed[item3] {
-		ok3, row3 := checkMountRowWithAutoMount(item3, MDC2_IP, NAS_60, "未检测到 60 盘。")
+		ok3, row3 := checkMountRowWithAutoMount(item3, MDC2_IP, MDC2_MOUNT_TARGETS)
 		rows = append(rows, row3)
 		allOK = allOK && ok3
 	}
@@ -598,67 +1924,364 @@ func runFailedOnlyCheck(prevRows []Row) (bool, []Row) {
 		if len(selected) == 0 {
 			return nil, true
 		}
+		return runPmuploadGroup(host, selected, maxWorkers)
+	}
+
+	m1Rows, m1OK := runSelected(MDC1_IP, MDC1_TOPIC_CMDS, workersFor(MDC1_IP, "MDC1A", MDC1_MAX_WORKERS))
+	rows = append(rows, m1Rows...)
+	allOK = allOK && m1OK
+
+	m2Rows, m2OK := runSelected(MDC2_IP, MDC2_TOPIC_CMDS, workersFor(MDC2_IP, "MDC2", MDC2_MAX_WORKERS))
+	rows = append(rows, m2Rows...)
+	allOK = allOK && m2OK
+
+	return allOK, rows
+}
+
+// ---------- Prometheus 指标 (-metrics-addr) ----------
+
+// metricSet 按 Prometheus 文本格式（exposition format 0.0.4）累积指标，
+// 同名指标的样本集中输出在一组 HELP/TYPE 之后
+type metricSet struct {
+	names   []string
+	help    map[string]string
+	samples map[string][]string
+}
+
+func newMetricSet() *metricSet {
+	return &metricSet{help: make(map[string]string), samples: make(map[string][]string)}
+}
 
-		orderedNames := make([]string, len(selected))
-		for i, item := range selected {
-			orderedNames[i] = item.Name
+// add 追加一个 gauge 样本，labels 为 name, value 交替排列
+func (m *metricSet) add(name, help string, value float64, labels ...string) {
+	if _, ok := m.help[name]; !ok {
+		m.names = append(m.names, name)
+		m.help[name] = help
+	}
+	var b strings.Builder
+	b.WriteString(name)
+	if len(labels) > 0 {
+		b.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			fmt.Fprintf(&b, "%s=\"%s\"", labels[i], escapeLabel(labels[i+1]))
+		}
+		b.WriteByte('}')
+	}
+	b.WriteByte(' ')
+	b.WriteString(strconv.FormatFloat(value, 'f', -1, 64))
+	m.samples[name] = append(m.samples[name], b.String())
+}
+
+func (m *metricSet) String() string {
+	var b strings.Builder
+	for _, name := range m.names {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s gauge\n", name, m.help[name], name)
+		for _, line := range m.samples[name] {
+			b.WriteString(line)
+			b.WriteByte('\n')
 		}
+	}
+	return b.String()
+}
+
+func escapeLabel(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return strings.ReplaceAll(s, "\n", `\n`)
+}
+
+func boolGauge(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// watchMetrics 最近一轮监控的指标文本，由 /metrics 返回
+var (
+	watchMetricsMu sync.Mutex
+	watchMetrics   string
+)
 
-		rowMap := make(map[string]Row)
-		okMap := make(map[string]bool)
-		var mu sync.Mutex
+// serveWatchMetrics 在后台监听 addr 提供 /metrics
+func serveWatchMetrics(addr string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		watchMetricsMu.Lock()
+		body := watchMetrics
+		watchMetricsMu.Unlock()
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		io.WriteString(w, body)
+	})
+	go func() {
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			fmt.Fprintf(os.Stderr, "指标服务启动失败: %v\n", err)
+		}
+	}()
+}
 
-		sem := make(chan struct{}, maxWorkers)
-		var wg sync.WaitGroup
+// ---------- 行驶中持续监控 (-watch) ----------
 
-		for _, item := range selected {
-			wg.Add(1)
-			go func(name, cmd string) {
-				defer wg.Done()
-				sem <- struct{}{}
-				defer func() { <-sem }()
+// watchMountRow 只读检查挂载：确认候选目标仍挂载且可读写，并根据上一轮可用字节数判断是否仍在写入；
+// 同时返回当前使用的挂载目标及其可用字节数。
+// 用 df -k 按字节比较：df -h 在 T 级容量下只显示约 0.1T 的步长，正常的一轮写入常常看不出变化。
+// 行驶中绝不自动重挂，避免破坏正在进行的录制。
+func watchMountRow(item, host string, targets []MountTarget, prevAvail int64, hasPrev bool) (bool, Row, string, int64) {
+	client, err := dialHost(host, nil, 1)
+	if err != nil {
+		return false, Row{item, FAIL, "SSH 连接失败"}, "", 0
+	}
+	defer client.Close()
 
-				n, row, ok := runPmuploadCheck(host, name, cmd)
-				mu.Lock()
-				rowMap[n] = row
-				okMap[n] = ok
-				mu.Unlock()
-			}(item.Name, item.Cmd)
+	_, dfOut, _, _ := execCmd(client, "df -k", CMD_TIMEOUT)
+	for _, t := range targets {
+		parts := dfFields(dfOut, t)
+		if parts == nil {
+			continue
 		}
+		availKB, err := strconv.ParseInt(parts[3], 10, 64)
+		if err != nil || !checkMountAlive(client, t) {
+			return false, Row{item, FAIL, fmt.Sprintf("%s 挂载点无法读写", t.Source)}, t.Source, 0
+		}
+		avail := availKB * 1024
+		availStr := fmt.Sprintf("%.1fG", float64(avail)/(1<<30))
+		if float64(avail) < WATCH_MIN_AVAIL_GB*(1<<30) {
+			return false, Row{item, FAIL, fmt.Sprintf("%s 可用容量仅剩 %s", t.Source, availStr)}, t.Source, avail
+		}
+		if hasPrev {
+			written := prevAvail - avail
+			if written <= 0 {
+				return false, Row{item, FAIL, fmt.Sprintf("%s 可用容量 %s，本轮无新增写入，录制可能已停止", t.Source, availStr)}, t.Source, avail
+			}
+			return true, Row{item, OK, fmt.Sprintf("%s 可用容量 %s，本轮写入 %.2fG", t.Source, availStr, float64(written)/(1<<30))}, t.Source, avail
+		}
+		return true, Row{item, OK, fmt.Sprintf("%s 可用容量 %s", t.Source, availStr)}, t.Source, avail
+	}
+	return false, Row{item, FAIL, "未检测到挂载"}, "", 0
+}
+
+// itemCategory 按检测项编号返回指标的 category 标签，与 check_json 相同
+func itemCategory(id int) string {
+	switch {
+	case id == 1:
+		return "car"
+	case id <= 3:
+		return "mount"
+	default:
+		return "topic"
+	}
+}
+
+// splitItem 把表格行的 "4. MDC1A 左侧 DTOF" 拆成编号和名称，与 check_json 指标中的 id/name 标签一致
+func splitItem(item string) (int, string) {
+	num, name, ok := strings.Cut(item, ". ")
+	id, err := strconv.Atoi(num)
+	if !ok || err != nil {
+		return 0, item
+	}
+	return id, name
+}
+
+func meanInts(arr []int) float64 {
+	if len(arr) == 0 {
+		return 0
+	}
+	sum := 0
+	for _, v := range arr {
+		sum += v
+	}
+	return float64(sum) / float64(len(arr))
+}
 
-		wg.Wait()
+// addWatchMetrics 按 check_json 的指标格式写入一轮监控的结果：检测项、Topic 采样和 SSH 连接耗时。
+// durations 为挂载等非 Topic 项的耗时，Topic 项的耗时取自 topicMetrics
+func addWatchMetrics(m *metricSet, vehicle string, rows []Row, rowHosts []string, durations map[string]float64) {
+	for i, r := range rows {
+		id, name := splitItem(r.Item)
+		labels := []string{"vehicle", vehicle, "host", rowHosts[i], "id", strconv.Itoa(id), "name", name, "category", itemCategory(id)}
+		skipped := isSkipStatus(r.Status)
+		if !skipped {
+			m.add("check_car_check_pass", "检测项是否通过", boolGauge(!isFailStatus(r.Status)), labels...)
+		}
+		m.add("check_car_check_skipped", "检测项是否因车辆状态前置条件未满足而跳过", boolGauge(skipped), labels...)
 
-		result := make([]Row, len(orderedNames))
-		resultOK := true
-		for i, name := range orderedNames {
-			result[i] = rowMap[name]
-			if !okMap[name] {
-				resultOK = false
+		duration := durations[r.Item]
+		tm, isTopic := topicMetrics.Load(r.Item)
+		if isTopic {
+			duration = tm.(topicMetric).Duration
+		}
+		m.add("check_car_check_duration_seconds", "检测项耗时", duration, labels...)
+
+		if isTopic && itemCategory(id) == "topic" {
+			hz := tm.(topicMetric).Hz
+			topic := []string{"vehicle", vehicle, "host", rowHosts[i], "name", name}
+			windows := hz.windows()
+			m.add("check_car_topic_windows", "Topic 各采样 windows 的平均值", meanInts(windows), topic...)
+			m.add("check_car_topic_window_samples", "Topic 解析到的 windows 采样数", float64(len(windows)), topic...)
+			if hz.hasTiming() {
+				m.add("check_car_topic_rate_hz", "Topic 各采样平均频率的均值", hz.MeanRate, topic...)
+				m.add("check_car_topic_max_delta_seconds", "Topic 消息最大间隔", hz.MaxDelta, topic...)
+				m.add("check_car_topic_std_dev_seconds", "Topic 消息间隔标准差（各采样中的最大值）", hz.MaxStdDev, topic...)
 			}
 		}
-		return result, resultOK
 	}
 
-	m1Rows, m1OK := runSelected(MDC1_IP, MDC1_TOPIC_CMDS, MDC1_MAX_WORKERS)
-	rows = append(rows, m1Rows...)
-	allOK = allOK && m1OK
+	var hosts []string
+	sshConnectSeconds.Range(func(k, _ any) bool {
+		hosts = append(hosts, k.(string))
+		return true
+	})
+	sort.Strings(hosts)
+	for _, h := range hosts {
+		v, _ := sshConnectSeconds.Load(h)
+		m.add("check_car_ssh_connect_seconds", "SSH 连接建立耗时", v.(float64), "vehicle", vehicle, "host", h)
+	}
+}
 
-	m2Rows, m2OK := runSelected(MDC2_IP, MDC2_TOPIC_CMDS, MDC2_MAX_WORKERS)
-	rows = append(rows, m2Rows...)
-	allOK = allOK && m2OK
+// runWatch 初检通过后按 interval 循环执行轻量检测（挂载存活、NAS 写入增长、Topic 发布），
+// 刷新状态表格；某项由正常变为异常时输出醒目告警并响铃。按 Ctrl+C 退出。
+func runWatch(interval time.Duration) {
+	mounts := []struct {
+		Item    string
+		Host    string
+		Targets []MountTarget
+	}{
+		{fmt.Sprintf("2. %s MDC1A", MDC1_IP), MDC1_IP, MDC1_MOUNT_TARGETS},
+		{fmt.Sprintf("3. %s MDC2", MDC2_IP), MDC2_IP, MDC2_MOUNT_TARGETS},
+	}
+	lastAvail := make(map[string]int64)
+	lastOK := make(map[string]bool)
+	startTime := time.Now()
+	vehicle := vehicleID
+
+	for round := 1; ; round++ {
+		roundStart := time.Now()
+		resetVehicleState()
+		var rows []Row
+		var rowHosts []string
+		durations := make(map[string]float64)
+		metrics := newMetricSet()
+
+		for _, m := range mounts {
+			prev, hasPrev := lastAvail[m.Item]
+			start := time.Now()
+			_, row, target, avail := watchMountRow(m.Item, m.Host, m.Targets, prev, hasPrev)
+			durations[m.Item] = time.Since(start).Seconds()
+			if avail > 0 {
+				lastAvail[m.Item] = avail
+				metrics.add("check_car_nas_avail_bytes", "NAS 挂载目标可用容量", float64(avail),
+					"vehicle", vehicle, "host", m.Host, "target", target)
+			}
+			rows = append(rows, row)
+			rowHosts = append(rowHosts, m.Host)
+		}
 
-	return allOK, rows
+		mdc1Rows, _ := runPmuploadGroup(MDC1_IP, MDC1_TOPIC_CMDS, workersFor(MDC1_IP, "MDC1A", MDC1_MAX_WORKERS))
+		rows = append(rows, mdc1Rows...)
+		for range mdc1Rows {
+			rowHosts = append(rowHosts, MDC1_IP)
+		}
+		mdc2Rows, _ := runPmuploadGroup(MDC2_IP, MDC2_TOPIC_CMDS, workersFor(MDC2_IP, "MDC2", MDC2_MAX_WORKERS))
+		rows = append(rows, mdc2Rows...)
+		for range mdc2Rows {
+			rowHosts = append(rowHosts, MDC2_IP)
+		}
+
+		var degraded, failing []string
+		for _, r := range rows {
+			ok := !isFailStatus(r.Status)
+			if !ok {
+				failing = append(failing, r.Item)
+				// 初检已全部通过，首轮出现的失败同样视为行驶中劣化
+				if prev, seen := lastOK[r.Item]; !seen || prev {
+					degraded = append(degraded, r.Item)
+				}
+			}
+			lastOK[r.Item] = ok
+		}
+
+		addWatchMetrics(metrics, vehicle, rows, rowHosts, durations)
+		metrics.add("check_car_watch_round", "行驶中监控已完成轮数", float64(round), "vehicle", vehicle)
+		metrics.add("check_car_watch_round_duration_seconds", "本轮监控耗时", time.Since(roundStart).Seconds(), "vehicle", vehicle)
+		metrics.add("check_car_run_timestamp_seconds", "本轮监控完成时间（Unix 秒）", float64(time.Now().Unix()), "vehicle", vehicle)
+		watchMetricsMu.Lock()
+		watchMetrics = metrics.String()
+		watchMetricsMu.Unlock()
+
+		clearScreen()
+		fmt.Printf("行驶中监控 第 %d 轮  更新时间 %s  已运行 %s  间隔 %s（Ctrl+C 退出）\n\n",
+			round, time.Now().Format("15:04:05"), time.Since(startTime).Round(time.Second), interval)
+		printTable(rows)
+		fmt.Println()
+		if len(failing) == 0 {
+			fmt.Println(GREEN + "全部正常。" + RESET)
+		} else {
+			fmt.Printf("%s!!! 告警：%s 异常 !!!%s\n", RED, strings.Join(failing, "、"), RESET)
+		}
+		if len(degraded) > 0 {
+			fmt.Print(strings.Repeat("\a", 3))
+		}
+
+		if wait := interval - time.Since(roundStart); wait > 0 {
+			time.Sleep(wait)
+		}
+	}
 }
 
 func main() {
+	watchFlag := flag.Bool("watch", false, "初检通过后进入行驶中持续监控模式")
+	intervalFlag := flag.Duration("interval", WATCH_INTERVAL, "持续监控的检测间隔")
+	metricsAddrFlag := flag.String("metrics-addr", "", "持续监控时在该地址提供 Prometheus /metrics，如 :9105")
+	flag.BoolVar(&verbose, "verbose", false, "显示失败项执行的远程命令及输出，并保存完整命令记录")
+	flag.Func("max-workers", "按主机或 MDC 名称设置 pmupload 最大并发数，如 MDC1A=1,192.168.30.143=2",
+		func(s string) error { return parseWorkerOverrides(s, workerOverrides) })
+	flag.BoolVar(&batchEnabled, "batch", false, "同主机的 Topic 合并为一次 pmupload 调用（需 pmupload 支持一次监听多个 topic，默认逐个执行）")
+	flag.StringVar(&proxyJump, "jump", proxyJump, "经跳板机连接车内主机，如 192.168.30.43 或 ops@bastion:2222,192.168.30.43（可用 -inventory 中 hosts 的别名）")
+	inventoryFlag := flag.String("inventory", "", "从车队清单（与 check_json 相同格式）读取主机连接配置和各 MDC 的候选挂载目标")
+	flag.StringVar(&vehicleID, "vehicle", vehicleID, "车辆编号，用于从清单中选车及指标标签")
+	flag.Parse()
+
+	if *inventoryFlag != "" {
+		if err := loadInventory(*inventoryFlag, vehicleID); err != nil {
+			fmt.Fprintf(os.Stderr, "读取车队清单失败: %v\n", err)
+			os.Exit(2)
+		}
+	}
+
+	onSuccess := func(rows []Row) {
+		fmt.Println("车辆正常，可以正常采集驾驶信息。")
+		var skipped []string
+		for _, r := range rows {
+			if isSkipStatus(r.Status) {
+				skipped = append(skipped, r.Item)
+			}
+		}
+		if len(skipped) > 0 {
+			fmt.Printf("%s以下检测项因车辆状态前置条件未满足已跳过，不计为失败，调整车辆状态后重新运行复检：%s%s\n",
+				YELLOW, strings.Join(skipped, "、"), RESET)
+		}
+		if *watchFlag {
+			fmt.Printf("%s 后进入行驶中监控...\n", *intervalFlag)
+			time.Sleep(*intervalFlag)
+			if *metricsAddrFlag != "" {
+				serveWatchMetrics(*metricsAddrFlag)
+			}
+			runWatch(*intervalFlag)
+		}
+	}
+
 	var lastRows []Row
 	for {
 		clearScreen()
 		ok, lastRows := runFullCheck()
 		printTable(lastRows)
+		showEvidence(lastRows)
 
 		if ok {
-			fmt.Println("车辆正常，可以正常采集驾驶信息。")
+			onSuccess(lastRows)
 			return
 		}
 
@@ -677,11 +2300,12 @@ func main() {
 				okFailed, rowsFailed := runFailedOnlyCheck(lastRows)
 				if len(rowsFailed) > 0 {
 					printTable(rowsFailed)
+					showEvidence(rowsFailed)
 				} else {
 					fmt.Println("无失败项需要复检。")
 				}
 				if okFailed {
-					fmt.Println("车辆正常，可以正常采集驾驶信息。")
+					onSuccess(rowsFailed)
 					return
 				}
 				fmt.Println("按 R 重启全量检测，按 X 继续只检测失败项，按 Q 退出。")
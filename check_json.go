@@ -5,7 +5,20 @@
 //   ./check_json -items=car         # 只检测车机
 //   ./check_json -items=mount       # 只检测挂载
 //   ./check_json -items=topic       # 只检测Topic
+//   ./check_json -events            # 以 NDJSON 逐行输出检测进度事件
+//   ./check_json -format=junit      # JUnit XML / TAP（-format=tap）输出，供 CI 使用
+//   ./check_json -report=report.html   # 同时生成单文件 HTML 报告
+//   ./check_json -record=session.tar   # 录制全部 SSH 交互；-replay=session.tar 离线重跑并比对结果
 //   ./check_json -help              # 显示帮助
+//   ./check_json verify -since="2026-01-02 09:00"   # 采集后校验录制数据
+//   ./check_json serve -port=5000   # 启动 HTTP 服务（REST API + Web 页面）
+//   ./check_json history list       # 查看检测历史
+//   ./check_json report trends      # Topic windows / NAS 容量趋势
+//   ./check_json fleet check -inventory=inventory.json   # 按车队清单并发检测多辆车
+//   ./check_json -jump=192.168.30.43                     # 经跳板机连接车内主机
+//   ./check_json -batch             # 同主机的 Topic 合并为一次 pmupload 调用（需 pmupload 支持）
+//   ./check_json collect -vehicle=V001   # 收集各主机日志和状态，打包为 tar.gz 附到问题单
+//   ./check_json discover                # 列出 MDC 上发布的 Topic，输出配置片段
 //
 // 编译:
 //   CGO_ENABLED=0 GOOS=linux go build -ldflags="-s -w" -o check_json check_json.go
@@ -13,19 +26,36 @@
 package main
 
 import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	_ "embed"
+	"encoding/csv"
 	"encoding/json"
+	"encoding/xml"
+	"errors"
 	"flag"
 	"fmt"
+	"html/template"
 	"io"
+	"math"
 	"net"
+	"net/http"
 	"os"
+	"path"
+	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
+	"unicode"
 
 	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
 )
 
 // ===== 固定配置 =====
@@ -43,6 +73,11 @@ const (
 	CMD_TIMEOUT       = 8 * time.Second
 	PMUPLOAD_TIMEOUT  = 20 * time.Second
 	MOUNT_TIMEOUT_SEC = 8
+	MOUNT_CMD_TIMEOUT = (MOUNT_TIMEOUT_SEC + 5) * time.Second // 需大于远端 timeout，才能拿到其 124 退出码
+
+	SMB_PORT               = 445
+	NFS_PORT               = 2049
+	PORT_PROBE_TIMEOUT_SEC = 3
 
 	MDC1_IP = "192.168.30.41"
 	MDC2_IP = "192.168.30.143"
@@ -55,28 +90,90 @@ const (
 
 	MDC1_MAX_WORKERS = 2
 	MDC2_MAX_WORKERS = 4
+
+	VERIFY_TIMEOUT         = 60 * time.Second
+	VERIFY_MAX_GAP         = 2 * time.Minute
+	VERIFY_TRUNCATED_RATIO = 0.1
+	VERIFY_CLOCK_MARGIN    = 60 * time.Minute // 按远端时钟粗筛录制文件时多留的余量，容忍 MDC 与本机的时钟偏差
+
+	SERVE_MAX_RUNS = 50
+
+	TREND_MIN_DAYS     = 3
+	TREND_DRIFT_RATIO  = 0.2
+	TREND_SPARK_POINTS = 40
+
+	FLEET_MAX_CONCURRENCY = 2
+
+	PMUPLOAD_LIST_CMD = "timeout 8s pmupload adstopic list"
+	PMUPLOAD_HZ_CMD   = "timeout 8s pmupload adstopic hz"
+	PMUPLOAD_ECHO_CMD = "timeout 8s pmupload adstopic echo"
+
+	DEFAULT_VEHICLE_STATE_CMD = "timeout 8s pmupload adstopic echo /vehicle_state -n 1"
+
+	CONTENT_PROBE_COUNT  = 3
+	LATENCY_SAMPLE_COUNT = 10
+
+	COLLECT_TIMEOUT        = 30 * time.Second
+	COLLECT_MAX_FILE_BYTES = 20 * 1024 * 1024
+)
+
+// 挂载类型
+const (
+	MOUNT_CIFS  = "cifs"  // Source: //NAS_IP/share
+	MOUNT_NFS   = "nfs"   // Source: NAS_IP:/export
+	MOUNT_LOCAL = "local" // Source: 本地块设备，如 /dev/nvme0n1p1
 )
 
+// MountTarget 描述一个可挂载到 MOUNT_POINT 的存储目标。
+// Type 为空时按 cifs 处理，Options 为空时使用该类型的默认选项。
+type MountTarget struct {
+	Type    string `json:"type"`
+	Source  string `json:"source"`
+	Options string `json:"options,omitempty"`
+}
+
+// 挂载配置：每台 MDC 按优先级列出候选挂载目标，首选不可用时依次尝试后备
+var MDC1_MOUNT_TARGETS = []MountTarget{
+	{Type: MOUNT_CIFS, Source: "//" + NAS_160 + "/nas"},
+	{Type: MOUNT_CIFS, Source: "//" + NAS_60 + "/nas"},
+}
+var MDC2_MOUNT_TARGETS = []MountTarget{
+	{Type: MOUNT_CIFS, Source: "//" + NAS_60 + "/nas"},
+	{Type: MOUNT_CIFS, Source: "//" + NAS_160 + "/nas"},
+}
+
 // Topic 映射
+// TopicCmd 一个 Topic 的 hz 检测命令。MinRate/MaxJitter/MaxGap 为可选阈值，为 0 时只按 windows 判定
 type TopicCmd struct {
-	Name string
-	Cmd  string
+	Name      string  `json:"name"`
+	Cmd       string  `json:"cmd"`
+	MinRate   float64 `json:"min_rate_hz,omitempty"`        // 平均频率下限
+	MaxJitter float64 `json:"max_jitter_seconds,omitempty"` // 间隔标准差上限
+	MaxGap    float64 `json:"max_gap_seconds,omitempty"`    // 最大间隔上限
+
+	Probe   *ContentProbe `json:"probe,omitempty"`   // 内容抽检，hz 检测通过后执行
+	Latency *LatencyCheck `json:"latency,omitempty"` // 端到端时延检测，hz 检测通过后执行
+
+	// Requires 该 Topic 发布所需的车辆状态，如 {"gear": "D", "brake": "on"}，多个可接受值用 | 分隔；
+	// Topic 未发布时读取车辆状态，不满足则报告前置条件未满足而不是传感器故障
+	Requires map[string]string `json:"requires,omitempty"`
 }
 
 var MDC1_TOPIC_CMDS = []TopicCmd{
-	{"MDC1A 左侧 DTOF", "timeout 8s pmupload adstopic hz /dtof_left"},
-	{"MDC1A 右侧 DTOF", "timeout 8s pmupload adstopic hz /dtof_right"},
-	{"MDC1A 后向 DTOF", "timeout 8s pmupload adstopic hz /dtof_rear"},
-	{"MDC1A 感知目标列表", "timeout 8s pmupload adstopic hz /object_array"},
-	{"MDC1A 融合感知目标列表", "timeout 8s pmupload adstopic hz /object_array_fusion"},
-	{"MDC1A 前向激光雷达", "timeout 8s pmupload adstopic hz /lidar_side_front"},
+	{Name: "MDC1A 左侧 DTOF", Cmd: "timeout 8s pmupload adstopic hz /dtof_left"},
+	{Name: "MDC1A 右侧 DTOF", Cmd: "timeout 8s pmupload adstopic hz /dtof_right"},
+	{Name: "MDC1A 后向 DTOF", Cmd: "timeout 8s pmupload adstopic hz /dtof_rear"},
+	{Name: "MDC1A 感知目标列表", Cmd: "timeout 8s pmupload adstopic hz /object_array"},
+	{Name: "MDC1A 融合感知目标列表", Cmd: "timeout 8s pmupload adstopic hz /object_array_fusion"},
+	{Name: "MDC1A 前向激光雷达", Cmd: "timeout 8s pmupload adstopic hz /lidar_side_front",
+		Requires: map[string]string{"gear": "D", "brake": "on"}},
 }
 
 var MDC2_TOPIC_CMDS = []TopicCmd{
-	{"MDC2 后向激光雷达", "timeout 8s pmupload adstopic hz /lidar_side_rear"},
-	{"MDC2 右侧激光雷达", "timeout 8s pmupload adstopic hz /lidar_side_right"},
-	{"MDC2 车顶激光雷达", "timeout 8s pmupload adstopic hz /lidar_side_roof"},
-	{"MDC2 左侧激光雷达", "timeout 8s pmupload adstopic hz /lidar_side_left"},
+	{Name: "MDC2 后向激光雷达", Cmd: "timeout 8s pmupload adstopic hz /lidar_side_rear"},
+	{Name: "MDC2 右侧激光雷达", Cmd: "timeout 8s pmupload adstopic hz /lidar_side_right"},
+	{Name: "MDC2 车顶激光雷达", Cmd: "timeout 8s pmupload adstopic hz /lidar_side_roof"},
+	{Name: "MDC2 左侧激光雷达", Cmd: "timeout 8s pmupload adstopic hz /lidar_side_left"},
 }
 
 // 检测项ID映射
@@ -94,47 +191,463 @@ var ITEM_MAP = map[int]string{
 	11: "topic_lidar_side_right",
 	12: "topic_lidar_side_roof",
 	13: "topic_lidar_side_left",
+	14: "catalog_mdc1",
+	15: "catalog_mdc2",
+}
+
+// ---------- 车辆配置 / 车队清单 ----------
+
+// HostConfig 主机连接配置，键为 hosts 中引用的主机名（可以是别名）。
+// 未配置的主机名直接按 IP/域名 连接 22 端口。
+type HostConfig struct {
+	Addr         string `json:"addr"`
+	Port         int    `json:"port,omitempty"`
+	User         string `json:"user,omitempty"`          // 默认 USERNAME
+	Password     string `json:"password,omitempty"`      // user 为 USERNAME 时默认 PASSWORD，其他账号需单独配置
+	IdentityFile string `json:"identity_file,omitempty"` // 私钥文件，如 ~/.ssh/id_ed25519（不支持带口令的私钥）
+	Agent        bool   `json:"agent,omitempty"`         // 使用 ssh-agent（SSH_AUTH_SOCK）中的密钥
+	LocalAddr    string `json:"local_addr,omitempty"`    // 多网卡/VLAN 时指定本机出口地址
+	ProxyJump    string `json:"proxy_jump,omitempty"`    // 跳板机，多跳用逗号分隔（同 ssh -J），按顺序连接
+}
+
+// MDCConfig 一台 MDC 的检测配置
+type MDCConfig struct {
+	Name         string        `json:"name"`
+	Host         string        `json:"host"`
+	MountTargets []MountTarget `json:"mount_targets"`
+	Topics       []TopicCmd    `json:"topics"`
+	MaxWorkers   int           `json:"max_workers"`
+	IgnoreTopics []string      `json:"ignore_topics,omitempty"` // Topic 目录比对时忽略的 topic，支持通配符
+	RecordGroups []string      `json:"record_groups,omitempty"` // verify 时期望出现的录制目录（相对录制目录），默认取各 Topic 名
+}
+
+// VehicleConfig 一辆车的检测配置。检测项 ID：1 车机，2/3 两台 MDC 的挂载，
+// 之后依次为 MDC1、MDC2 的 Topic（默认配置下为 4-9、10-13），最后两项为 MDC1、MDC2 的 Topic 目录比对。
+type VehicleConfig struct {
+	ID    string              `json:"id"`
+	Hosts []string            `json:"hosts"`
+	MDC1  MDCConfig           `json:"mdc1"`
+	MDC2  MDCConfig           `json:"mdc2"`
+	State *VehicleStateSource `json:"vehicle_state,omitempty"` // 车辆状态读取方式，默认在 MDC1 上执行 DEFAULT_VEHICLE_STATE_CMD
+}
+
+// VehicleStateSource 档位/刹车/点火等车辆状态的读取方式：命令输出 "字段: 值" 或 "字段=值" 形式的行，
+// 可以是 pmupload echo 某个底盘 topic，也可以是解码 CAN 信号的脚本
+type VehicleStateSource struct {
+	Host   string            `json:"host,omitempty"`   // 执行命令的主机，默认 MDC1
+	Cmd    string            `json:"cmd,omitempty"`    // 默认 DEFAULT_VEHICLE_STATE_CMD
+	Fields map[string]string `json:"fields,omitempty"` // 状态名到输出字段名的映射，如 {"gear": "gear_location"}，未列出的按同名字段读取
+}
+
+// Inventory 车队清单文件
+type Inventory struct {
+	MaxConcurrency int                   `json:"max_concurrency"`
+	Hosts          map[string]HostConfig `json:"hosts"`
+	Vehicles       []VehicleConfig       `json:"vehicles"`
+}
+
+// hostConfigs 由 loadInventory 在检测开始前填充，之后只读
+var hostConfigs = map[string]HostConfig{}
+
+// defaultProxyJump 未配置 proxy_jump 的主机使用的跳板机，由 -jump 或 CHECK_CAR_JUMP 指定
+var defaultProxyJump = os.Getenv("CHECK_CAR_JUMP")
+
+func init() {
+	if s := os.Getenv("CHECK_CAR_MAX_WORKERS"); s != "" {
+		if err := parseWorkerOverrides(s, workerOverrides); err != nil {
+			fmt.Fprintf(os.Stderr, "CHECK_CAR_MAX_WORKERS: %v\n", err)
+		}
+	}
+}
+
+func addJumpFlag(fs *flag.FlagSet) {
+	fs.StringVar(&defaultProxyJump, "jump", defaultProxyJump,
+		"经跳板机连接车内主机，如 192.168.30.43 或 ops@bastion:2222,192.168.30.43（多跳按顺序，逗号分隔）")
+}
+
+// workerOverrides 按主机或 MDC 名称覆盖 pmupload 最大并发数，由 -max-workers 或 CHECK_CAR_MAX_WORKERS 指定
+var workerOverrides = map[string]int{}
+
+// parseWorkerOverrides 解析 "MDC1A=1,192.168.30.143=2" 形式的并发配置，键不区分大小写
+func parseWorkerOverrides(s string, into map[string]int) error {
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		key, val, ok := strings.Cut(part, "=")
+		n, err := strconv.Atoi(strings.TrimSpace(val))
+		if !ok || err != nil || n <= 0 || strings.TrimSpace(key) == "" {
+			return fmt.Errorf("无效的并发配置 %q，格式为 主机或MDC名称=正整数", part)
+		}
+		into[strings.ToLower(strings.TrimSpace(key))] = n
+	}
+	return nil
+}
+
+func addWorkersFlag(fs *flag.FlagSet) {
+	fs.Func("max-workers", "按主机或 MDC 名称设置 pmupload 最大并发数，如 MDC1A=1,192.168.30.143=2（覆盖配置中的 max_workers）",
+		func(s string) error { return parseWorkerOverrides(s, workerOverrides) })
+}
+
+// workers 返回该 MDC 的 pmupload 最大并发数：-max-workers 中按主机、再按名称匹配，否则取配置
+func (m MDCConfig) workers() int {
+	for _, key := range []string{m.Host, m.Name} {
+		if n, ok := workerOverrides[strings.ToLower(key)]; ok {
+			return n
+		}
+	}
+	if m.MaxWorkers <= 0 {
+		return 1
+	}
+	return m.MaxWorkers
+}
+
+// resolveHost 返回主机的连接配置。name 可以是清单 hosts 中的别名，也可以是 [user@]addr[:port]
+func resolveHost(name string) HostConfig {
+	hc, ok := hostConfigs[name]
+	if !ok {
+		hc = HostConfig{Addr: name}
+		if i := strings.LastIndex(hc.Addr, "@"); i >= 0 {
+			hc.User, hc.Addr = hc.Addr[:i], hc.Addr[i+1:]
+		}
+		if h, p, err := net.SplitHostPort(hc.Addr); err == nil {
+			hc.Addr = h
+			hc.Port, _ = strconv.Atoi(p)
+		}
+	}
+	if hc.Port == 0 {
+		hc.Port = PORT
+	}
+	if hc.User == "" {
+		hc.User = USERNAME
+	}
+	if hc.Password == "" && hc.User == USERNAME {
+		hc.Password = PASSWORD
+	}
+	if !ok && hc.User != USERNAME {
+		// -jump=ops@bastion 这类未在清单中配置的非车内账号，不发送车内 root 密码，改用 ssh-agent
+		hc.Agent = true
+	}
+	if hc.ProxyJump == "" {
+		hc.ProxyJump = defaultProxyJump
+	}
+	return hc
+}
+
+// endpoint 返回 user@addr:port，用于判断两跳是否为同一连接
+func (hc HostConfig) endpoint() string {
+	return hc.User + "@" + net.JoinHostPort(hc.Addr, strconv.Itoa(hc.Port))
+}
+
+// jumpHops 返回连接 host 需要依次经过的跳板机。链路中出现目标主机自身（用户、地址和端口都相同）时
+// 截断在它之前（例如跳板机就是车内网关 192.168.30.43，检测网关本身时直连）；
+// 跳板机自己的 proxy_jump 不再展开。
+func jumpHops(host string, hc HostConfig) []string {
+	var hops []string
+	for _, hop := range strings.Split(hc.ProxyJump, ",") {
+		hop = strings.TrimSpace(hop)
+		if hop == "" {
+			continue
+		}
+		if hop == host || resolveHost(hop).endpoint() == hc.endpoint() {
+			break
+		}
+		hops = append(hops, hop)
+	}
+	return hops
+}
+
+// defaultVehicle 返回未使用清单文件时的单车配置（即上面的固定配置）
+func defaultVehicle() VehicleConfig {
+	return VehicleConfig{
+		Hosts: HOSTS,
+		MDC1: MDCConfig{
+			Name:         "MDC1A",
+			Host:         MDC1_IP,
+			MountTargets: MDC1_MOUNT_TARGETS,
+			Topics:       MDC1_TOPIC_CMDS,
+			MaxWorkers:   MDC1_MAX_WORKERS,
+		},
+		MDC2: MDCConfig{
+			Name:         "MDC2",
+			Host:         MDC2_IP,
+			MountTargets: MDC2_MOUNT_TARGETS,
+			Topics:       MDC2_TOPIC_CMDS,
+			MaxWorkers:   MDC2_MAX_WORKERS,
+		},
+	}
+}
+
+// topicStartIDs 返回 MDC1、MDC2 第一个 Topic 检测项的 ID
+func (v VehicleConfig) topicStartIDs() (int, int) {
+	return 4, 4 + len(v.MDC1.Topics)
+}
+
+// lastTopicID 返回最后一个 Topic 检测项的 ID
+func (v VehicleConfig) lastTopicID() int {
+	_, start2 := v.topicStartIDs()
+	return start2 + len(v.MDC2.Topics) - 1
+}
+
+// catalogIDs 返回 MDC1、MDC2 Topic 目录比对检测项的 ID
+func (v VehicleConfig) catalogIDs() (int, int) {
+	last := v.lastTopicID()
+	return last + 1, last + 2
+}
+
+// maxItemID 返回最大的检测项 ID
+func (v VehicleConfig) maxItemID() int {
+	_, cat2 := v.catalogIDs()
+	return cat2
+}
+
+// selectVehicle 未指定清单时返回默认配置，否则返回清单中编号为 id 的车辆
+func selectVehicle(inventoryPath, id string) (VehicleConfig, error) {
+	if inventoryPath == "" {
+		return defaultVehicle(), nil
+	}
+	inv, err := loadInventory(inventoryPath)
+	if err != nil {
+		return VehicleConfig{}, fmt.Errorf("读取车队清单失败: %v", err)
+	}
+	for _, v := range inv.Vehicles {
+		if v.ID == id {
+			return v, nil
+		}
+	}
+	return VehicleConfig{}, fmt.Errorf("清单中没有车辆 %s", id)
+}
+
+// loadInventory 读取车队清单，校验每辆车的配置并登记主机连接配置
+func loadInventory(path string) (Inventory, error) {
+	var inv Inventory
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return inv, err
+	}
+	if err := json.Unmarshal(data, &inv); err != nil {
+		return inv, fmt.Errorf("解析清单 %s 失败: %v", path, err)
+	}
+
+	seen := make(map[string]bool)
+	for i := range inv.Vehicles {
+		v := &inv.Vehicles[i]
+		if v.ID == "" || seen[v.ID] {
+			return inv, fmt.Errorf("第 %d 辆车的 id 为空或重复", i+1)
+		}
+		seen[v.ID] = true
+		if v.MDC1.Host == "" || v.MDC2.Host == "" {
+			return inv, fmt.Errorf("车辆 %s 缺少 mdc1/mdc2 的 host", v.ID)
+		}
+		if len(v.Hosts) == 0 {
+			v.Hosts = []string{v.MDC1.Host, v.MDC2.Host}
+		}
+		for _, m := range []*MDCConfig{&v.MDC1, &v.MDC2} {
+			if m.MaxWorkers <= 0 {
+				m.MaxWorkers = 1
+			}
+			for _, t := range m.MountTargets {
+				if !validMountType(t.Type) || t.Source == "" {
+					return inv, fmt.Errorf("车辆 %s 的挂载目标 %q 类型 %q 无效（可选 cifs/nfs/local）或 source 为空", v.ID, t.Source, t.Type)
+				}
+			}
+		}
+		if v.MDC1.Name == "" {
+			v.MDC1.Name = "MDC1A"
+		}
+		if v.MDC2.Name == "" {
+			v.MDC2.Name = "MDC2"
+		}
+	}
+	if inv.MaxConcurrency <= 0 {
+		inv.MaxConcurrency = FLEET_MAX_CONCURRENCY
+	}
+
+	for name, hc := range inv.Hosts {
+		hostConfigs[name] = hc
+	}
+	return inv, nil
 }
 
 var PMUPLOAD_WINDOW_RE = regexp.MustCompile(`^\s*/\S+.*\s(\d+)\s*$`)
+var MOUNT_ERROR_RE = regexp.MustCompile(`mount error\((\d+)\)`)
 
 // JSON 输出结构
 type CheckResult struct {
-	Timestamp   string                `json:"timestamp"`
-	Success     bool                  `json:"success"`
-	Duration    float64               `json:"duration_seconds"`
-	Passed      map[string]ResultItem `json:"passed"`
-	Failed      map[string]ResultItem `json:"failed"`
-	FailedCount int                   `json:"failed_count"`
-	TotalCount  int                   `json:"total_count"`
+	Timestamp    string                `json:"timestamp"`
+	Success      bool                  `json:"success"`
+	Duration     float64               `json:"duration_seconds"`
+	Passed       map[string]ResultItem `json:"passed"`
+	Failed       map[string]ResultItem `json:"failed"`
+	Skipped      map[string]ResultItem `json:"skipped,omitempty"` // 车辆状态前置条件未满足、无法判定的项，不计为失败
+	FailedCount  int                   `json:"failed_count"`
+	SkippedCount int                   `json:"skipped_count,omitempty"`
+	TotalCount   int                   `json:"total_count"`
+	Cancelled    bool                  `json:"cancelled,omitempty"`
+	SSHConnect   map[string]float64    `json:"ssh_connect_seconds,omitempty"` // 车机检测中各主机 SSH 连接耗时
 }
 
 type ResultItem struct {
-	Name    string `json:"name"`
-	Message string `json:"message"`
+	Name     string         `json:"name"`
+	Host     string         `json:"host,omitempty"` // 执行检测的主机
+	Message  string         `json:"message"`
+	Windows  []int          `json:"windows,omitempty"`          // Topic 检测解析出的 windows
+	Hz       *HzStats       `json:"hz,omitempty"`               // Topic 检测解析出的频率/间隔统计
+	Content  *ContentResult `json:"content,omitempty"`          // Topic 内容抽检结果
+	Latency  *LatencyResult `json:"latency,omitempty"`          // Topic 时延检测结果
+	Target   string         `json:"target,omitempty"`           // 挂载检测最终使用的挂载目标
+	AvailGB  float64        `json:"avail_gb,omitempty"`         // 挂载目标可用容量（GB）
+	Duration float64        `json:"duration_seconds,omitempty"` // 该项检测耗时
+
+	SerialRetry  bool       `json:"serial_retry,omitempty"` // 并发时无输出，已串行重跑
+	Batched      bool       `json:"batched,omitempty"`      // hz 结果来自同主机合并执行的一次 pmupload
+	Precondition string     `json:"precondition,omitempty"` // 未满足的车辆状态前置条件，如 "gear=P"；此时该项列入 skipped，不代表传感器故障
+	Output       string     `json:"output,omitempty"`       // 判定所依据的原始命令输出
+	Remediation  []string   `json:"remediation,omitempty"`  // 自动修复动作
+	Evidence     []Evidence `json:"evidence,omitempty"`     // 该项执行的全部远程命令
 }
 
 // 内部使用的检测结果
 type internalResult struct {
-	ID      int
-	Name    string
-	OK      bool
-	Message string
+	ID       int
+	Name     string
+	Host     string
+	OK       bool
+	Message  string
+	Windows  []int
+	Hz       *HzStats
+	Content  *ContentResult
+	Latency  *LatencyResult
+	Target   string
+	AvailGB  float64
+	Duration float64
+
+	Attempts     int // pmupload 执行次数，串行重跑时接着编号
+	SerialRetry  bool
+	Batched      bool
+	Precondition string
+	Output       string
+	Remediation  []string
+	Evidence     []Evidence
+}
+
+// ---------- 进度事件 ----------
+
+// 事件类型
+const (
+	EVENT_RUN_STARTED         = "run_started"
+	EVENT_CHECK_STARTED       = "check_started"
+	EVENT_CHECK_ATTEMPT       = "check_attempt"
+	EVENT_CHECK_FINISHED      = "check_finished"
+	EVENT_REMEDIATION_APPLIED = "remediation_applied"
+	EVENT_RUN_FINISHED        = "run_finished"
+)
+
+// Event 检测过程中的进度事件，CLI 以 NDJSON 输出，HTTP 服务以 SSE 推送
+type Event struct {
+	Type    string       `json:"type"`
+	Time    string       `json:"time"`
+	RunID   string       `json:"run_id,omitempty"`
+	ID      int          `json:"id,omitempty"`
+	Name    string       `json:"name,omitempty"`
+	Attempt int          `json:"attempt,omitempty"`
+	OK      *bool        `json:"ok,omitempty"`
+	Skipped bool         `json:"skipped,omitempty"` // check_finished：前置条件未满足而跳过，不计为失败
+	Message string       `json:"message,omitempty"`
+	Result  *CheckResult `json:"result,omitempty"`
+}
+
+type eventSinkKey struct{}
+
+// withEventSink 返回携带事件回调的 ctx；回调可能被多个检测 goroutine 并发调用
+func withEventSink(ctx context.Context, sink func(Event)) context.Context {
+	return context.WithValue(ctx, eventSinkKey{}, sink)
+}
+
+func emit(ctx context.Context, ev Event) {
+	sink, ok := ctx.Value(eventSinkKey{}).(func(Event))
+	if !ok {
+		return
+	}
+	ev.Time = time.Now().Format(time.RFC3339Nano)
+	sink(ev)
+}
+
+func emitCheckStarted(ctx context.Context, id int, name string) {
+	emit(ctx, Event{Type: EVENT_CHECK_STARTED, ID: id, Name: name})
+}
+
+// finishCheck 发出 check_finished 事件并原样返回结果
+func finishCheck(ctx context.Context, r internalResult) internalResult {
+	ok := r.OK
+	emit(ctx, Event{Type: EVENT_CHECK_FINISHED, ID: r.ID, Name: r.Name, OK: &ok, Skipped: !ok && r.Precondition != "", Message: r.Message})
+	return r
+}
+
+// ndjsonSink 把事件逐行写成 JSON
+func ndjsonSink(w io.Writer) func(Event) {
+	var mu sync.Mutex
+	enc := json.NewEncoder(w)
+	return func(ev Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		enc.Encode(ev)
+	}
 }
 
 // ---------- SSH helpers ----------
+
+// sshConnect 连接主机，按配置依次经过跳板机。返回的连接关闭后，途经的跳板连接随之关闭。
 func sshConnect(host string) (*ssh.Client, error) {
+	hc := resolveHost(host)
+
+	var via *ssh.Client
+	for _, hop := range jumpHops(host, hc) {
+		next, err := sshDial(via, resolveHost(hop))
+		if err != nil {
+			if via != nil {
+				via.Close()
+			}
+			return nil, fmt.Errorf("连接跳板机 %s 失败: %v", hop, err)
+		}
+		via = next
+	}
+
+	client, err := sshDial(via, hc)
+	if err != nil && via != nil {
+		via.Close()
+	}
+	return client, err
+}
+
+// sshDial 建立一跳 SSH 连接：via 为空时直接 TCP 连接，否则经 via 转发
+func sshDial(via *ssh.Client, hc HostConfig) (*ssh.Client, error) {
+	auth, closeAuth, err := sshAuthMethods(hc)
+	if err != nil {
+		return nil, err
+	}
+	defer closeAuth()
 	config := &ssh.ClientConfig{
-		User: USERNAME,
-		Auth: []ssh.AuthMethod{
-			ssh.Password(PASSWORD),
-		},
+		User:            hc.User,
+		Auth:            auth,
 		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
 		Timeout:         CONNECT_TIMEOUT,
 	}
 
-	addr := fmt.Sprintf("%s:%d", host, PORT)
-	conn, err := net.DialTimeout("tcp", addr, CONNECT_TIMEOUT)
+	addr := net.JoinHostPort(hc.Addr, strconv.Itoa(hc.Port))
+	var conn net.Conn
+	if via == nil {
+		dialer := net.Dialer{Timeout: CONNECT_TIMEOUT}
+		if hc.LocalAddr != "" {
+			dialer.LocalAddr = &net.TCPAddr{IP: net.ParseIP(hc.LocalAddr)}
+		}
+		conn, err = dialer.Dial("tcp", addr)
+	} else {
+		ctx, cancel := context.WithTimeout(context.Background(), CONNECT_TIMEOUT)
+		conn, err = via.DialContext(ctx, "tcp", addr)
+		cancel()
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -145,11 +658,57 @@ func sshConnect(host string) (*ssh.Client, error) {
 		return nil, err
 	}
 
-	return ssh.NewClient(c, chans, reqs), nil
+	client := ssh.NewClient(c, chans, reqs)
+	if via != nil {
+		go func() {
+			client.Wait()
+			via.Close()
+		}()
+	}
+	return client, nil
 }
 
-func trySSH(host string) bool {
-	client, err := sshConnect(host)
+// sshAuthMethods 返回一跳的认证方式，依次尝试私钥文件、ssh-agent 和密码。
+// 返回的 close 用于在握手结束后关闭与 ssh-agent 的连接。
+func sshAuthMethods(hc HostConfig) ([]ssh.AuthMethod, func(), error) {
+	var methods []ssh.AuthMethod
+	closeAuth := func() {}
+	if hc.IdentityFile != "" {
+		path := hc.IdentityFile
+		if rest, ok := strings.CutPrefix(path, "~/"); ok {
+			if home, err := os.UserHomeDir(); err == nil {
+				path = filepath.Join(home, rest)
+			}
+		}
+		key, err := os.ReadFile(path)
+		if err != nil {
+			return nil, closeAuth, fmt.Errorf("读取私钥失败: %v", err)
+		}
+		signer, err := ssh.ParsePrivateKey(key)
+		if err != nil {
+			return nil, closeAuth, fmt.Errorf("解析私钥 %s 失败: %v", hc.IdentityFile, err)
+		}
+		methods = append(methods, ssh.PublicKeys(signer))
+	}
+	if hc.Agent {
+		if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+			if conn, err := net.DialTimeout("unix", sock, CONNECT_TIMEOUT); err == nil {
+				methods = append(methods, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+				closeAuth = func() { conn.Close() }
+			}
+		}
+	}
+	if hc.Password != "" {
+		methods = append(methods, ssh.Password(hc.Password))
+	}
+	if len(methods) == 0 {
+		return nil, closeAuth, fmt.Errorf("%s@%s 没有可用的认证方式（请配置 password、identity_file，或设置 SSH_AUTH_SOCK 使用 ssh-agent）", hc.User, hc.Addr)
+	}
+	return methods, closeAuth, nil
+}
+
+func trySSH(host string, log *evidenceLog) bool {
+	client, err := dialHost(host, log, 1)
 	if err != nil {
 		return false
 	}
@@ -157,7 +716,205 @@ func trySSH(host string) bool {
 	return true
 }
 
-func execCmd(client *ssh.Client, cmd string, timeout time.Duration) (int, string, string, error) {
+// ---------- 命令记录 (evidence) ----------
+
+// Evidence 检测过程中执行的一条远程命令（或一次 SSH 连接）的记录，敏感信息已脱敏
+type Evidence struct {
+	Host     string `json:"host"`
+	Command  string `json:"command"`
+	Start    string `json:"start"`
+	End      string `json:"end"`
+	ExitCode int    `json:"exit_code"`
+	Stdout   string `json:"stdout,omitempty"`
+	Stderr   string `json:"stderr,omitempty"`
+	Error    string `json:"error,omitempty"`
+	Attempt  int    `json:"attempt"`
+	PTY      bool   `json:"pty"` // 是否分配伪终端；本工具执行命令时从不请求 PTY，恒为 false
+}
+
+// evidenceLog 一个检测项的命令记录，可被多个 goroutine 并发追加；nil 表示不记录
+type evidenceLog struct {
+	mu    sync.Mutex
+	items []Evidence
+	scope string // 所属检测项（如检测项 ID），录制/回放时据此把连接和命令归到各自的检测项
+}
+
+// scopeOf 返回命令记录所属的检测项，nil 时为空
+func (l *evidenceLog) scopeOf() string {
+	if l == nil {
+		return ""
+	}
+	return l.scope
+}
+
+func (l *evidenceLog) add(e Evidence) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	l.items = append(l.items, e)
+	l.mu.Unlock()
+}
+
+func (l *evidenceLog) list() []Evidence {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]Evidence(nil), l.items...)
+}
+
+// SECRET_KV_RE 匹配 password=xxx、token=xxx 以及 NAS_PASS=xxx、API_TOKEN=xxx 这类带前缀的变量
+var SECRET_KV_RE = regexp.MustCompile(`(?i)\b(\w*(?:pass|passwd|password|token|secret))=([^,\s'"]+)`)
+
+// redactSecrets 隐去命令和输出中的密码：key=value 形式的凭据以及已知的 SSH/NAS 密码
+func redactSecrets(s string) string {
+	s = SECRET_KV_RE.ReplaceAllString(s, "$1=***")
+	secrets := []string{PASSWORD, NAS_PASS}
+	for _, hc := range hostConfigs {
+		if hc.Password != "" {
+			secrets = append(secrets, hc.Password)
+		}
+	}
+	for _, secret := range secrets {
+		s = strings.ReplaceAll(s, secret, "***")
+	}
+	return s
+}
+
+// Executor 连接主机并执行命令的方式：默认直接走 SSH，-record 时边执行边录制，-replay 时从录制文件回放。
+// 所有检测都经 dialHost/execCmd 访问主机，因此换一个 Executor 即可离线重跑整个检测流程。
+type Executor interface {
+	Dial(host, scope string) (Conn, error) // scope 为发起连接的检测项，只用于录制/回放
+}
+
+// Conn 到一台主机的连接
+type Conn interface {
+	Exec(cmd string, timeout time.Duration) (exitCode int, stdout, stderr string, err error)
+	Close() error
+}
+
+// executor 当前使用的 Executor，在 main 中按 -record/-replay 设置后不再改变
+var executor Executor = sshExecutor{}
+
+type sshExecutor struct{}
+
+func (sshExecutor) Dial(host, scope string) (Conn, error) {
+	client, err := sshConnect(host)
+	if err != nil {
+		return nil, err
+	}
+	return sshClientConn{client}, nil
+}
+
+type sshClientConn struct {
+	client *ssh.Client
+}
+
+func (c sshClientConn) Exec(cmd string, timeout time.Duration) (int, string, string, error) {
+	return execSSH(c.client, cmd, timeout)
+}
+
+func (c sshClientConn) Close() error {
+	return c.client.Close()
+}
+
+// sshConn 一条到主机的连接；经 execCmd 执行的命令都会记入 log
+type sshConn struct {
+	conn    Conn
+	host    string
+	log     *evidenceLog
+	attempt int
+}
+
+func (c *sshConn) Close() error {
+	return c.conn.Close()
+}
+
+// dialHost 连接主机并把连接本身也记入 log；attempt 为该检测项的第几次尝试
+func dialHost(host string, log *evidenceLog, attempt int) (*sshConn, error) {
+	start := time.Now()
+	conn, err := executor.Dial(host, log.scopeOf())
+	e := Evidence{
+		Host:    host,
+		Command: "ssh " + host,
+		Start:   start.Format(time.RFC3339Nano),
+		End:     time.Now().Format(time.RFC3339Nano),
+		Attempt: attempt,
+	}
+	if err != nil {
+		e.ExitCode = -1
+		e.Error = redactSecrets(err.Error())
+		log.add(e)
+		return nil, err
+	}
+	log.add(e)
+	return &sshConn{conn: conn, host: host, log: log, attempt: attempt}, nil
+}
+
+func saveTranscript(path string, result CheckResult) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := writeTranscript(f, result); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writeTranscript 把检测结果中各项的命令记录写成便于阅读的文本
+func writeTranscript(w io.Writer, result CheckResult) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "检测时间 %s  结果 %s  耗时 %.1fs\n", result.Timestamp, statusText(result.Success), result.Duration)
+
+	ids, items, ok := resultItems(result)
+	for _, id := range ids {
+		it := items[id]
+		fmt.Fprintf(&b, "\n===== %d. %s [%s] =====\n%s\n", id, it.Name, itemStatusText(ok[id], it), it.Message)
+		for _, e := range it.Evidence {
+			fmt.Fprintf(&b, "\n--- [%s] 第 %d 次 %s $ %s\n", e.Host, e.Attempt, e.Start, e.Command)
+			fmt.Fprintf(&b, "    结束 %s  退出码 %d  PTY %v\n", e.End, e.ExitCode, e.PTY)
+			if e.Error != "" {
+				fmt.Fprintf(&b, "    错误: %s\n", e.Error)
+			}
+			if e.Stdout != "" {
+				fmt.Fprintf(&b, "[stdout]\n%s\n", strings.TrimRight(e.Stdout, "\n"))
+			}
+			if e.Stderr != "" {
+				fmt.Fprintf(&b, "[stderr]\n%s\n", strings.TrimRight(e.Stderr, "\n"))
+			}
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// execCmd 执行远程命令并记入连接的命令记录
+func execCmd(client *sshConn, cmd string, timeout time.Duration) (int, string, string, error) {
+	start := time.Now()
+	code, stdout, stderr, err := client.conn.Exec(cmd, timeout)
+	e := Evidence{
+		Host:     client.host,
+		Command:  redactSecrets(cmd),
+		Start:    start.Format(time.RFC3339Nano),
+		End:      time.Now().Format(time.RFC3339Nano),
+		ExitCode: code,
+		Stdout:   redactSecrets(stdout),
+		Stderr:   redactSecrets(stderr),
+		Attempt:  client.attempt,
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	client.log.add(e)
+	return code, stdout, stderr, err
+}
+
+func execSSH(client *ssh.Client, cmd string, timeout time.Duration) (int, string, string, error) {
 	session, err := client.NewSession()
 	if err != nil {
 		return -1, "", "", err
@@ -188,309 +945,4583 @@ func execCmd(client *ssh.Client, cmd string, timeout time.Duration) (int, string
 				exitCode = -1
 			}
 		}
-		return exitCode, stdout.String(), stderr.String(), nil
-	case <-time.After(timeout):
-		session.Signal(ssh.SIGTERM)
-		return -1, stdout.String(), stderr.String(), fmt.Errorf("timeout")
+		return exitCode, stdout.String(), stderr.String(), nil
+	case <-time.After(timeout):
+		session.Signal(ssh.SIGTERM)
+		return -1, stdout.String(), stderr.String(), fmt.Errorf("timeout")
+	}
+}
+
+// ---------- df / mount ----------
+func parseSizeToGB(sizeStr string) (float64, bool) {
+	s := strings.TrimSpace(sizeStr)
+	re := regexp.MustCompile(`^([0-9]*\.?[0-9]+)\s*([KMGTP])([iI]?)B?$`)
+	m := re.FindStringSubmatch(s)
+	if m == nil {
+		return 0, false
+	}
+
+	val, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0, false
+	}
+
+	unit := strings.ToUpper(m[2])
+	factors := map[string]float64{
+		"K": 1.0 / (1024 * 1024),
+		"M": 1.0 / 1024,
+		"G": 1.0,
+		"T": 1024.0,
+		"P": 1024.0 * 1024.0,
+	}
+
+	return val * factors[unit], true
+}
+
+// dfFields 在 df 输出中查找该挂载目标挂在 MOUNT_POINT 上的行并返回各列，未挂载时返回 nil。
+// 文件系统列须与目标一致、挂载点列须为 MOUNT_POINT；源过长被 df 折成两行时先合并
+func dfFields(dfOut string, t MountTarget) []string {
+	lines := strings.Split(dfOut, "\n")
+	for i := 0; i < len(lines); i++ {
+		parts := strings.Fields(lines[i])
+		if len(parts) == 1 && i+1 < len(lines) {
+			if next := strings.Fields(lines[i+1]); len(next) == 5 {
+				parts = append(parts, next...)
+				i++
+			}
+		}
+		if len(parts) >= 6 && dfSourceMatches(parts[0], t) && parts[len(parts)-1] == MOUNT_POINT {
+			return parts
+		}
+	}
+	return nil
+}
+
+// dfFindMountAvail 在 df -h 输出中查找该挂载目标，返回 (已挂载, 可用容量原文, 可用容量GB, 解析成功)
+func dfFindMountAvail(dfOut string, t MountTarget) (bool, string, float64, bool) {
+	parts := dfFields(dfOut, t)
+	if parts == nil {
+		return false, "", 0, false
+	}
+	availStr := parts[3]
+	availGB, ok := parseSizeToGB(availStr)
+	return true, availStr, availGB, ok
+}
+
+// targetHost 返回网络存储的主机地址（cifs: //host/share，nfs: host:/export），本地盘返回空串
+func targetHost(t MountTarget) string {
+	switch t.Type {
+	case MOUNT_CIFS, "":
+		return strings.SplitN(strings.TrimPrefix(t.Source, "//"), "/", 2)[0]
+	case MOUNT_NFS:
+		return strings.SplitN(t.Source, ":", 2)[0]
+	}
+	return ""
+}
+
+// dfSourceMatches 判断 df 的文件系统列是否就是该挂载目标：cifs 按 NAS 主机比较（共享名写法可能不同），
+// nfs 忽略末尾的 /，本地盘精确比较（/dev/nvme0n1p1 不匹配 /dev/nvme0n1p10）
+func dfSourceMatches(source string, t MountTarget) bool {
+	switch t.Type {
+	case MOUNT_CIFS, "":
+		return strings.HasPrefix(source, "//") && strings.EqualFold(targetHost(MountTarget{Source: source}), targetHost(t))
+	case MOUNT_NFS:
+		return strings.TrimRight(source, "/") == strings.TrimRight(t.Source, "/")
+	}
+	return source == t.Source
+}
+
+// validMountType 检查配置中的挂载类型，空串按 cifs 处理
+func validMountType(typ string) bool {
+	switch typ {
+	case "", MOUNT_CIFS, MOUNT_NFS, MOUNT_LOCAL:
+		return true
+	}
+	return false
+}
+
+func buildMountCmd(t MountTarget) string {
+	var mountCmd string
+	switch t.Type {
+	case MOUNT_NFS:
+		opts := t.Options
+		if opts == "" {
+			opts = "soft,timeo=50,retrans=2,nolock"
+		}
+		mountCmd = fmt.Sprintf("mount -t nfs %s %s -o %s", t.Source, MOUNT_POINT, opts)
+	case MOUNT_LOCAL:
+		mountCmd = fmt.Sprintf("mount %s %s", t.Source, MOUNT_POINT)
+		if t.Options != "" {
+			mountCmd += " -o " + t.Options
+		}
+	default:
+		opts := t.Options
+		if opts == "" {
+			opts = fmt.Sprintf("vers=2.0,username=%s,password=%s,cache=strict,"+
+				"uid=1000,forceuid,gid=1000,forcegid,"+
+				"file_mode=0755,dir_mode=0755,soft,nounix,noserverino,mapposix,"+
+				"rsize=65536,wsize=65536,bsize=1048576,echo_interval=60,actimeo=1",
+				NAS_USER, NAS_PASS)
+		}
+		mountCmd = fmt.Sprintf("mount -t cifs %s %s -o %s", t.Source, MOUNT_POINT, opts)
+	}
+
+	return fmt.Sprintf("mkdir -p %s; umount -l %s 2>/dev/null || true; timeout %ds %s",
+		MOUNT_POINT, MOUNT_POINT, MOUNT_TIMEOUT_SEC, mountCmd)
+}
+
+// checkMountAlive 确认挂载点可真实访问（避免 stale 假挂）；本地盘额外确认块设备仍然存在
+func checkMountAlive(client *sshConn, t MountTarget) bool {
+	if t.Type == MOUNT_LOCAL {
+		code0, _, _, _ := execCmd(client, fmt.Sprintf("test -b %s", t.Source), CMD_TIMEOUT)
+		if code0 != 0 {
+			return false
+		}
+	}
+	code1, _, _, _ := execCmd(client, fmt.Sprintf("ls %s >/dev/null 2>&1", MOUNT_POINT), CMD_TIMEOUT)
+	if code1 != 0 {
+		return false
+	}
+	code2, _, _, _ := execCmd(client, fmt.Sprintf("touch %s/.__nas_test__ && rm -f %s/.__nas_test__ >/dev/null 2>&1", MOUNT_POINT, MOUNT_POINT), CMD_TIMEOUT)
+	return code2 == 0
+}
+
+// findMountUsers 列出占用挂载点的进程（PID + 命令名）。
+// 是否为挂载点按 /proc/mounts 判断，不访问挂载点本身；不是挂载点时不检查，否则 fuser -m 会把根文件系统上的所有进程都算进来。
+// fuser/lsof 超时（挂载已卡死）或无法完成检查时返回错误，调用方应拒绝重挂。
+func findMountUsers(client *sshConn) ([]string, error) {
+	cmd := fmt.Sprintf("awk '$2==\"%s\"{f=1} END{exit !f}' /proc/mounts; m=$?; [ $m -eq 1 ] && exit 0; [ $m -eq 0 ] || exit $m; "+
+		"if command -v fuser >/dev/null 2>&1; then pids=$(timeout %ds fuser -m %s 2>/dev/null); rc=$?; "+
+		"elif command -v lsof >/dev/null 2>&1; then pids=$(timeout %ds lsof -t %s 2>/dev/null); rc=$?; "+
+		"else exit 127; fi; "+
+		"[ $rc -le 1 ] || exit $rc; "+
+		"for p in $pids; do echo \"$p $(cat /proc/$p/comm 2>/dev/null)\"; done",
+		MOUNT_POINT, MOUNT_TIMEOUT_SEC, MOUNT_POINT, MOUNT_TIMEOUT_SEC, MOUNT_POINT)
+	code, out, _, err := execCmd(client, cmd, MOUNT_CMD_TIMEOUT)
+	switch {
+	case err != nil:
+		return nil, fmt.Errorf("检查命令未完成: %v", err)
+	case code == 124:
+		return nil, fmt.Errorf("fuser/lsof 超过 %ds 未返回，挂载可能已卡死", MOUNT_TIMEOUT_SEC)
+	case code == 127:
+		return nil, fmt.Errorf("MDC 上没有 fuser 或 lsof")
+	case code != 0:
+		return nil, fmt.Errorf("检查命令退出码 %d", code)
+	}
+
+	var users []string
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(out, "\n") {
+		parts := strings.Fields(line)
+		if len(parts) == 0 {
+			continue
+		}
+		pid := strings.TrimRight(parts[0], "cefFrm")
+		if _, err := strconv.Atoi(pid); err != nil || seen[pid] {
+			continue
+		}
+		seen[pid] = true
+		name := "?"
+		if len(parts) >= 2 {
+			name = parts[1]
+		}
+		users = append(users, fmt.Sprintf("PID %s (%s)", pid, name))
+	}
+	return users, nil
+}
+
+// PROBE_UNREACHABLE_RE 端口探测输出中表示端口确实不可达的错误
+var PROBE_UNREACHABLE_RE = regexp.MustCompile(`(?i)connection refused|no route to host|network is unreachable|host is unreachable|timed out`)
+
+// probeTarget 挂载前的预检：网络存储探测服务端口是否可达，本地盘确认块设备存在。
+// 返回 (通过, 已探测, 失败原因)；只有超时或明确的拒绝/不可达才算探测失败。MDC 上既没有 bash 也没有 nc、
+// 或 nc 不支持 -z（busybox 常见）等无法判断的情况不算已探测，直接交给 mount 判断。
+func probeTarget(client *sshConn, t MountTarget) (bool, bool, string) {
+	if t.Type == MOUNT_LOCAL {
+		code, _, _, err := execCmd(client, fmt.Sprintf("test -b %s", t.Source), CMD_TIMEOUT)
+		if err != nil {
+			return false, false, ""
+		}
+		return code == 0, true, fmt.Sprintf("块设备 %s 不存在，请检查硬盘是否插好", t.Source)
+	}
+
+	host := targetHost(t)
+	port := SMB_PORT
+	if t.Type == MOUNT_NFS {
+		port = NFS_PORT
+	}
+	cmd := fmt.Sprintf("if command -v bash >/dev/null 2>&1; then timeout %ds bash -c '</dev/tcp/%s/%d' 2>&1; "+
+		"elif command -v nc >/dev/null 2>&1; then timeout %ds nc -z -v -w %d %s %d 2>&1; "+
+		"else exit 127; fi",
+		PORT_PROBE_TIMEOUT_SEC, host, port, PORT_PROBE_TIMEOUT_SEC, PORT_PROBE_TIMEOUT_SEC, host, port)
+	code, out, _, err := execCmd(client, cmd, CMD_TIMEOUT)
+	switch {
+	case err != nil:
+		return false, false, ""
+	case code == 0:
+		return true, true, ""
+	case code == 124 || PROBE_UNREACHABLE_RE.MatchString(out):
+		return false, true, fmt.Sprintf("%s 的 %d 端口不可达，请确认 NAS 已上电并连好网线", host, port)
+	}
+	return false, false, ""
+}
+
+// mountErrorReason 解析 mount 的错误输出，给出对应原因和处理建议；无法识别时返回空串
+func mountErrorReason(t MountTarget, exitCode int, output string) string {
+	if m := MOUNT_ERROR_RE.FindStringSubmatch(output); m != nil {
+		switch m[1] {
+		case "13":
+			return "挂载被拒绝 error(13)，NAS 用户名或密码错误"
+		case "2":
+			return fmt.Sprintf("共享不存在 error(2)，请检查 NAS 共享 %s 是否存在", t.Source)
+		case "112", "113":
+			return fmt.Sprintf("NAS 主机不可达 error(%s)，请确认 NAS 已上电并连好网线", m[1])
+		case "110", "115":
+			return fmt.Sprintf("连接 NAS 超时 error(%s)，请确认 NAS 已上电并连好网线", m[1])
+		default:
+			return fmt.Sprintf("mount error(%s)，请换盘", m[1])
+		}
+	}
+
+	lower := strings.ToLower(output)
+	switch {
+	case strings.Contains(lower, "access denied"), strings.Contains(lower, "permission denied"):
+		return fmt.Sprintf("挂载被拒绝，请检查 %s 的访问权限", t.Source)
+	case strings.Contains(lower, "no such file or directory"), strings.Contains(lower, "does not exist"):
+		return fmt.Sprintf("%s 不存在，请检查配置或硬盘是否插好", t.Source)
+	case strings.Contains(lower, "wrong fs type"):
+		return fmt.Sprintf("%s 文件系统无法识别，请换盘", t.Source)
+	case strings.Contains(lower, "connection refused"), strings.Contains(lower, "timed out"):
+		return "连接 NAS 失败，请确认 NAS 已上电并连好网线"
+	}
+
+	if exitCode == 124 {
+		return fmt.Sprintf("挂载超时（>%ds），请确认 NAS 已上电并连好网线", MOUNT_TIMEOUT_SEC)
+	}
+	return ""
+}
+
+// mountStatus 描述一台 MDC 的挂载检查结果
+type mountStatus struct {
+	OK        bool
+	Target    MountTarget // 最终使用的挂载目标
+	AvailStr  string
+	Remounted bool
+	Attempts  int // 实际清理并重挂的候选数
+	BusyUsers []string
+	BusyErr   error    // 占用检查未能完成（如挂载卡死），此时同样拒绝重挂
+	Failures  []string // 各候选目标的失败原因（按尝试顺序）
+	ConnErr   error
+	Actions   []string // 执行过的清理/重挂动作
+	Output    string   // 最后一次 df 及 mount 的原始输出
+}
+
+// targetUsage 一个挂载目标的检查结果
+type targetUsage struct {
+	OK       bool
+	Full     bool // 已挂载且可读写，只是可用容量不足；重挂无济于事
+	AvailStr string
+	Reason   string
+	DfOut    string
+}
+
+// checkTargetUsable 判断挂载目标是否挂载在 MOUNT_POINT 上、容量足够且可读写
+func checkTargetUsable(client *sshConn, t MountTarget) targetUsage {
+	_, dfOut, _, _ := execCmd(client, "df -h", CMD_TIMEOUT)
+	u := targetUsage{DfOut: dfOut}
+	mounted, availStr, availGB, ok := dfFindMountAvail(dfOut, t)
+	u.AvailStr = availStr
+	switch {
+	case !mounted:
+		u.Reason = "挂载失败，请换盘"
+	case !ok || availStr == "":
+		u.Reason = "盘状态异常，请换盘"
+	case !checkMountAlive(client, t):
+		u.Reason = "挂载点无法读写，请换盘"
+	case availGB < MIN_AVAIL_GB:
+		u.Full = true
+		u.Reason = fmt.Sprintf("可用容量 %s（<800G），请换盘", availStr)
+	default:
+		u.OK = true
+	}
+	return u
+}
+
+// ensureMount 按优先级检查候选挂载目标，当前挂载的候选可用则直接使用；
+// 否则依次清理并重挂每个候选（每个只尝试一次，不循环重试），直到找到可用的目标。
+// 若挂载点正被进程占用（例如正在录制），拒绝重挂并返回占用进程列表。
+func ensureMount(host string, targets []MountTarget, log *evidenceLog) mountStatus {
+	var st mountStatus
+	client, err := dialHost(host, log, 1)
+	if err != nil {
+		st.ConnErr = err
+		return st
+	}
+	defer client.Close()
+
+	full := make(map[MountTarget]bool)
+	for _, t := range targets {
+		u := checkTargetUsable(client, t)
+		st.Output = "$ df -h\n" + u.DfOut
+		if u.OK {
+			st.OK, st.Target, st.AvailStr = true, t, u.AvailStr
+			return st
+		}
+		if u.Full {
+			full[t] = true
+			st.Failures = append(st.Failures, fmt.Sprintf("%s %s（已挂载，未重挂）", t.Source, u.Reason))
+		}
+	}
+
+	users, err := findMountUsers(client)
+	if err != nil {
+		st.BusyErr = err
+		return st
+	}
+	if len(users) > 0 {
+		st.BusyUsers = users
+		return st
+	}
+
+	for _, t := range targets {
+		if full[t] {
+			continue
+		}
+		if passed, probed, reason := probeTarget(client, t); probed && !passed {
+			st.Failures = append(st.Failures, fmt.Sprintf("%s %s", t.Source, reason))
+			continue
+		}
+
+		st.Remounted = true
+		st.Attempts++
+		client.attempt++ // 每次重挂记为一次新的尝试
+		code, out, errOut, _ := execCmd(client, buildMountCmd(t), MOUNT_CMD_TIMEOUT)
+		st.Actions = append(st.Actions, fmt.Sprintf("清理 %s 并挂载 %s（退出码 %d）", MOUNT_POINT, t.Source, code))
+		u := checkTargetUsable(client, t)
+		st.Output = fmt.Sprintf("$ mount %s（退出码 %d）\n%s%s\n$ df -h\n%s", t.Source, code, out, errOut, u.DfOut)
+		if u.OK {
+			st.OK, st.Target, st.AvailStr = true, t, u.AvailStr
+			return st
+		}
+		reason := u.Reason
+		if detail := mountErrorReason(t, code, out+"\n"+errOut); detail != "" {
+			reason = detail
+		}
+		st.Failures = append(st.Failures, fmt.Sprintf("%s %s", t.Source, reason))
+	}
+	return st
+}
+
+// mountTip 根据挂载检查结果生成提醒文案
+func mountTip(st mountStatus, targets []MountTarget) string {
+	if st.ConnErr != nil {
+		return "SSH 连接失败，无法检查挂载，请上电或插上网线。"
+	}
+	if st.BusyErr != nil {
+		return fmt.Sprintf("无法确认挂载点 %s 是否被占用（%v），已拒绝自动重挂，请确认没有录制进程后手动处理。", MOUNT_POINT, st.BusyErr)
+	}
+	if len(st.BusyUsers) > 0 {
+		return fmt.Sprintf("挂载点 %s 正被占用，已拒绝自动重挂：%s，请先停止相关进程。", MOUNT_POINT, strings.Join(st.BusyUsers, ", "))
+	}
+	if !st.OK {
+		done := "未重挂"
+		if st.Attempts > 0 {
+			done = fmt.Sprintf("已自动清理并重挂 %d 个候选", st.Attempts)
+		}
+		return fmt.Sprintf("挂载失败或盘不可用（%s）：%s。", done, strings.Join(st.Failures, "；"))
+	}
+	if len(targets) > 0 && st.Target != targets[0] {
+		return fmt.Sprintf("首选 %s 不可用，已切换到备用 %s，可用容量 %s", targets[0].Source, st.Target.Source, st.AvailStr)
+	}
+	return fmt.Sprintf("%s 可用容量 %s", st.Target.Source, st.AvailStr)
+}
+
+func checkMountRow(ctx context.Context, id int, item, host string, targets []MountTarget) internalResult {
+	emitCheckStarted(ctx, id, item)
+	log := &evidenceLog{scope: strconv.Itoa(id)}
+	st := ensureMount(host, targets, log)
+	if st.Remounted {
+		ok := st.OK
+		msg := fmt.Sprintf("已清理 %s 并重挂", MOUNT_POINT)
+		if st.OK {
+			msg += "，当前使用 " + st.Target.Source
+		}
+		if len(st.Failures) > 0 {
+			msg += "；失败：" + strings.Join(st.Failures, "；")
+		}
+		emit(ctx, Event{Type: EVENT_REMEDIATION_APPLIED, ID: id, Name: item, OK: &ok, Message: msg})
+	}
+	r := internalResult{ID: id, Name: item, Host: host, OK: st.OK, Message: mountTip(st, targets),
+		Output: redactSecrets(st.Output), Remediation: st.Actions, Evidence: log.list()}
+	if st.OK {
+		r.Target = st.Target.Source
+		r.AvailGB, _ = parseSizeToGB(st.AvailStr)
+	}
+	return finishCheck(ctx, r)
+}
+
+// ---------- pmupload parsing ----------
+
+// HzSample pmupload hz 报告中一个 topic 的一次采样。间隔统一换算为秒，未输出的字段为 0
+type HzSample struct {
+	Topic    string  `json:"topic"`
+	Rate     float64 `json:"rate_hz"`
+	MinDelta float64 `json:"min_delta_seconds,omitempty"`
+	MaxDelta float64 `json:"max_delta_seconds,omitempty"`
+	StdDev   float64 `json:"std_dev_seconds,omitempty"`
+	Window   int     `json:"window"`
+}
+
+// HzStats pmupload hz 报告的解析结果及各采样的汇总
+type HzStats struct {
+	Samples   []HzSample `json:"samples"`
+	MeanRate  float64    `json:"mean_rate_hz"`
+	MinDelta  float64    `json:"min_delta_seconds"`   // 各采样最小间隔中的最小值
+	MaxDelta  float64    `json:"max_delta_seconds"`   // 各采样最大间隔中的最大值
+	MaxStdDev float64    `json:"max_std_dev_seconds"` // 各采样间隔标准差中的最大值
+}
+
+// HZ_KV_RE 匹配 "average rate: 9.99"、"min=0.098s"、"std dev: 1.2ms"、"window: 50" 等键值
+var HZ_KV_RE = regexp.MustCompile(`(?i)\b(average[ _]rate|avg[ _]rate|rate|hz|min[ _]delta|min|max[ _]delta|max|std[ _]?dev|std|window|windows)\s*[:=]\s*([-+]?[0-9]*\.?[0-9]+(?:[eE][-+]?[0-9]+)?)\s*(ms|us|s|hz)?\b`)
+
+// HZ_UNIT_SUFFIX_RE 表头中列名后的单位，如 rate(hz)、min(s)
+var HZ_UNIT_SUFFIX_RE = regexp.MustCompile(`\([^)]*\)`)
+
+// HZ_NUM_RE 匹配表格/位置格式中的数值（可带单位）
+var HZ_NUM_RE = regexp.MustCompile(`(?i)^([-+]?[0-9]*\.?[0-9]+(?:[eE][-+]?[0-9]+)?)(ms|us|s|hz)?$`)
+
+// hzField 把各种写法的字段名归一为 rate / min / max / std / window，无法识别时返回空
+func hzField(key string) string {
+	key = strings.ToLower(strings.NewReplacer(" ", "", "_", "", "-", "").Replace(key))
+	switch key {
+	case "averagerate", "avgrate", "rate", "hz":
+		return "rate"
+	case "mindelta", "min":
+		return "min"
+	case "maxdelta", "max":
+		return "max"
+	case "stddev", "std":
+		return "std"
+	case "window", "windows", "count":
+		return "window"
+	}
+	return ""
+}
+
+// hzSeconds 按单位把间隔换算为秒，无单位时按秒
+func hzSeconds(v float64, unit string) float64 {
+	switch strings.ToLower(unit) {
+	case "ms":
+		return v / 1e3
+	case "us":
+		return v / 1e6
+	}
+	return v
+}
+
+// set 写入一个字段，返回是否识别
+func (s *HzSample) set(field string, v float64, unit string) bool {
+	switch field {
+	case "rate":
+		s.Rate = v
+	case "min":
+		s.MinDelta = hzSeconds(v, unit)
+	case "max":
+		s.MaxDelta = hzSeconds(v, unit)
+	case "std":
+		s.StdDev = hzSeconds(v, unit)
+	case "window":
+		s.Window = int(v)
+	default:
+		return false
+	}
+	return true
+}
+
+// parseHzKV 解析一段文本中的键值字段，返回是否解析到任何字段以及是否包含 window
+func parseHzKV(s *HzSample, text string) (found, hasWindow bool) {
+	for _, m := range HZ_KV_RE.FindAllStringSubmatch(text, -1) {
+		v, err := strconv.ParseFloat(m[2], 64)
+		if err != nil {
+			continue
+		}
+		field := hzField(m[1])
+		if s.set(field, v, m[3]) {
+			found = true
+			hasWindow = hasWindow || field == "window"
+		}
+	}
+	return found, hasWindow
+}
+
+// parseHzHeader 识别表头行（含 window 列），返回各列对应的字段
+func parseHzHeader(line string) []string {
+	norm := strings.ToLower(line)
+	for _, pair := range [][2]string{{"average rate", "average_rate"}, {"avg rate", "avg_rate"}, {"min delta", "min_delta"},
+		{"max delta", "max_delta"}, {"std dev", "std_dev"}} {
+		norm = strings.ReplaceAll(norm, pair[0], pair[1])
+	}
+	cols := strings.Fields(HZ_UNIT_SUFFIX_RE.ReplaceAllString(norm, ""))
+	hasWindow := false
+	fields := make([]string, len(cols))
+	for i, c := range cols {
+		fields[i] = hzField(strings.Trim(c, "[]:|"))
+		hasWindow = hasWindow || fields[i] == "window"
+	}
+	if !hasWindow {
+		return nil
+	}
+	return fields
+}
+
+// parsePmuploadHz 解析 pmupload hz 的输出，兼容以下几种格式：
+//   - 单行键值：/topic average rate: 10.0 min: 0.098s max: 0.102s std dev: 0.0012s window: 50
+//   - 多行块：topic 独占一行，随后几行为键值（同 rostopic hz）
+//   - 表格：表头含 topic/rate/min/max/std dev/window 列，数据行以 topic 开头
+//   - 位置格式：/topic 后跟 rate min max std window 五个数，或只以 window 结尾（旧版本只解析这一种）
+//
+// 只有解析到 window 的采样才会保留，与旧版本按 window 判断 Topic 是否发布的逻辑一致。
+func parsePmuploadHz(text string) HzStats {
+	var samples []HzSample
+	var header []string
+	var pending *HzSample // 多行块中等待 window 的采样
+
+	flush := func() {
+		pending = nil
+	}
+
+	for _, raw := range strings.Split(text, "\n") {
+		line := strings.TrimSpace(strings.TrimRight(raw, "\r"))
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "/") {
+			if pending != nil {
+				if _, hasWindow := parseHzKV(pending, line); hasWindow {
+					samples = append(samples, *pending)
+					flush()
+				}
+				continue
+			}
+			if h := parseHzHeader(line); h != nil && !HZ_KV_RE.MatchString(line) {
+				header = h
+			}
+			continue
+		}
+
+		flush()
+		fields := strings.Fields(line)
+		s := HzSample{Topic: fields[0]}
+		rest := strings.TrimSpace(strings.TrimPrefix(line, fields[0]))
+		if rest == "" {
+			pending = &s
+			continue
+		}
+
+		// 有键值时只认 window 键，不按旧格式取行尾整数（如 "rate: 10" 的 10 是频率）；
+		// 没有 window 的键值行可能是多行块的开头，等后续行补上
+		if found, hasWindow := parseHzKV(&s, rest); found {
+			if hasWindow {
+				samples = append(samples, s)
+			} else {
+				pending = &s
+			}
+			continue
+		}
+
+		// 表格 / 位置格式：取出各列数值
+		var nums []float64
+		var units []string
+		for _, f := range fields[1:] {
+			if m := HZ_NUM_RE.FindStringSubmatch(strings.TrimSuffix(f, ",")); m != nil {
+				v, _ := strconv.ParseFloat(m[1], 64)
+				nums = append(nums, v)
+				units = append(units, m[2])
+			}
+		}
+		m := PMUPLOAD_WINDOW_RE.FindStringSubmatch(line)
+		switch {
+		case len(header) == len(fields) && len(nums) == len(fields)-1:
+			for i, v := range nums {
+				s.set(header[i+1], v, units[i])
+			}
+			samples = append(samples, s)
+		case m != nil:
+			s.Window, _ = strconv.Atoi(m[1])
+			if len(nums) == 5 {
+				s.Rate = nums[0]
+				s.MinDelta = hzSeconds(nums[1], units[1])
+				s.MaxDelta = hzSeconds(nums[2], units[2])
+				s.StdDev = hzSeconds(nums[3], units[3])
+			} else if len(nums) == 2 {
+				s.Rate = nums[0]
+			}
+			samples = append(samples, s)
+		}
+	}
+
+	return summarizeHz(samples)
+}
+
+// summarizeHz 汇总各采样；窗口为 0 的采样不参与频率和间隔统计
+func summarizeHz(samples []HzSample) HzStats {
+	stats := HzStats{Samples: samples}
+	if stats.Samples == nil {
+		stats.Samples = []HzSample{}
+	}
+	n := 0
+	for _, s := range samples {
+		if s.Window == 0 {
+			continue
+		}
+		n++
+		stats.MeanRate += s.Rate
+		if s.MinDelta > 0 && (stats.MinDelta == 0 || s.MinDelta < stats.MinDelta) {
+			stats.MinDelta = s.MinDelta
+		}
+		stats.MaxDelta = math.Max(stats.MaxDelta, s.MaxDelta)
+		stats.MaxStdDev = math.Max(stats.MaxStdDev, s.StdDev)
+	}
+	if n > 0 {
+		stats.MeanRate /= float64(n)
+	}
+	return stats
+}
+
+// windows 返回各采样的 window，用于原有的 windows 判定和历史趋势
+func (h HzStats) windows() []int {
+	var windows []int
+	for _, s := range h.Samples {
+		windows = append(windows, s.Window)
+	}
+	return windows
+}
+
+// hasTiming 是否解析到了频率/间隔（旧格式只有 window）
+func (h HzStats) hasTiming() bool {
+	for _, s := range h.Samples {
+		if s.Rate > 0 || s.MaxDelta > 0 || s.StdDev > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func allZero(arr []int) bool {
+	for _, v := range arr {
+		if v != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func hasZero(arr []int) bool {
+	for _, v := range arr {
+		if v == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// runPmuploadCheck 执行一个 Topic 检测项并发出 check_finished
+func runPmuploadCheck(ctx context.Context, id int, host string, topic TopicCmd) internalResult {
+	return finishCheck(ctx, pmuploadCheck(ctx, id, host, topic, nil))
+}
+
+// pmuploadCheck 执行一个 Topic 检测项，不发出 check_finished，由调用方决定何时结束。
+// prev 非 nil 时为串行重跑：不再发出 check_started，执行次数接着 prev 编号，命令记录追加在 prev 之后
+func pmuploadCheck(ctx context.Context, id int, host string, topic TopicCmd, prev *internalResult) internalResult {
+	attempt := 0
+	var output string
+	var client *sshConn // 最后一次尝试的连接，内容抽检和时延检测复用
+	log := &evidenceLog{scope: strconv.Itoa(id)}
+	if prev != nil {
+		attempt = prev.Attempts
+		log.items = append(log.items, prev.Evidence...)
+	}
+	defer func() {
+		if client != nil {
+			client.Close()
+		}
+	}()
+	runOnce := func() HzStats {
+		attempt++
+		if client != nil {
+			client.Close()
+		}
+		var stats HzStats
+		client, stats, output = runPmuploadOnce(host, topic.Cmd, log, attempt)
+		windows := stats.windows()
+		ok := len(windows) > 0 && !hasZero(windows)
+		emit(ctx, Event{Type: EVENT_CHECK_ATTEMPT, ID: id, Name: topic.Name, Attempt: attempt, OK: &ok,
+			Message: fmt.Sprintf("windows=%v", windows)})
+		return stats
+	}
+
+	if prev == nil {
+		emitCheckStarted(ctx, id, topic.Name)
+	}
+	stats := runOnce()
+	if windows := stats.windows(); len(windows) == 0 || allZero(windows) {
+		stats = runOnce()
+	}
+
+	// 有输出却没有采样：连接失败或 pmupload 报错，不是并发过高
+	reason := NO_SAMPLE_SILENT
+	switch {
+	case strings.TrimSpace(output) != "" && client == nil:
+		reason = NO_SAMPLE_SSH_FAILED
+	case strings.TrimSpace(output) != "":
+		reason = NO_SAMPLE_ERROR
+	case prev != nil:
+		reason = NO_SAMPLE_SERIAL
+	}
+	r := pmuploadVerdict(id, topic, stats, reason)
+	r.Attempts = attempt
+	if client != nil {
+		output = runTopicFollowUps(ctx, client, topic, &r, output)
+	}
+	r.Output = redactSecrets(output)
+	r.Evidence = log.list()
+	if windows := stats.windows(); len(topic.Requires) > 0 && (len(windows) == 0 || hasZero(windows)) {
+		applyPreconditions(ctx, &r, topic)
+	}
+	return r
+}
+
+// runTopicFollowUps hz 检测通过后在同一连接上执行内容抽检和时延检测，返回追加了其输出的 output
+func runTopicFollowUps(ctx context.Context, client *sshConn, topic TopicCmd, r *internalResult, output string) string {
+	if r.OK && topic.Probe != nil && ctx.Err() == nil {
+		cr, out := runContentProbe(client, topic)
+		r.Content = &cr
+		output += "\n--- " + cr.Cmd + " ---\n" + out
+		if len(cr.Problems) > 0 {
+			problems := cr.Problems
+			if len(problems) > 3 {
+				problems = append(problems[:3:3], fmt.Sprintf("等 %d 处", len(cr.Problems)))
+			}
+			r.OK = false
+			r.Message = fmt.Sprintf("内容抽检未通过: %s | %s | %s", strings.Join(problems, "；"), cr.Cmd, r.Message)
+		}
+	}
+	if r.OK && topic.Latency != nil && ctx.Err() == nil {
+		lr, out := runLatencyCheck(client, topic)
+		r.Latency = &lr
+		output += "\n--- " + lr.Cmd + " ---\n" + out
+		if lr.Problem != "" {
+			r.OK = false
+			r.Message = fmt.Sprintf("%s | %s", lr.Problem, r.Message)
+		} else {
+			r.Message += fmt.Sprintf(" latency_p50=%.3fs p95=%.3fs", lr.P50, lr.P95)
+		}
+		if !lr.LineBuffered && len(lr.Samples) > 0 {
+			r.Message += "（MDC 上没有 stdbuf，pmupload 输出为块缓冲，时延偏大）"
+		}
+	}
+	return output
+}
+
+// runPmuploadOnce 建立连接执行一次 pmupload 并解析 hz 报告，返回连接（供后续抽检复用，连接失败时为 nil）
+// 和原始输出；无输出时没有采样
+func runPmuploadOnce(host, cmd string, log *evidenceLog, attempt int) (*sshConn, HzStats, string) {
+	client, err := dialHost(host, log, attempt)
+	if err != nil {
+		return nil, summarizeHz(nil), fmt.Sprintf("SSH 连接失败: %v", err)
+	}
+
+	_, out, errOut, _ := execCmd(client, cmd, PMUPLOAD_TIMEOUT)
+	merged := out
+	if out != "" && errOut != "" {
+		merged += "\n"
+	}
+	merged += errOut
+	if strings.TrimSpace(merged) == "" {
+		return client, summarizeHz(nil), merged
+	}
+	return client, parsePmuploadHz(merged), merged
+}
+
+// pmuploadVerdict 根据 windows 判定 Topic 是否正常发布；配置了频率/抖动/间隔阈值时一并判定
+// noSampleReason 没有任何采样时判断出的原因，决定提示中列出的可能原因
+type noSampleReason int
+
+const (
+	NO_SAMPLE_SILENT     noSampleReason = iota // 连接成功但 pmupload 没有任何输出，可能是并发过高
+	NO_SAMPLE_ERROR                            // pmupload 有输出（如报错）但没有采样
+	NO_SAMPLE_SSH_FAILED                       // SSH 连接失败
+	NO_SAMPLE_SERIAL                           // 串行重跑仍没有任何输出，已排除并发原因
+)
+
+func (n noSampleReason) hint() string {
+	switch n {
+	case NO_SAMPLE_ERROR:
+		return "Topic未发布/跑错IP"
+	case NO_SAMPLE_SSH_FAILED:
+		return "SSH 连接失败"
+	case NO_SAMPLE_SERIAL:
+		return "串行重跑仍无输出，可能Topic未发布/跑错IP"
+	}
+	return "可能并发过高/Topic未发布/跑错IP"
+}
+
+// pmuploadVerdict 按采样结果判定；没有任何采样时按 reason 给出可能原因
+func pmuploadVerdict(id int, topic TopicCmd, stats HzStats, reason noSampleReason) internalResult {
+	cmd := topic.Cmd
+	windows := stats.windows()
+	tipList := fmt.Sprintf("windows=%v", windows)
+	if stats.hasTiming() {
+		tipList += fmt.Sprintf(" rate=%.2fHz max_delta=%.3fs std_dev=%.4fs", stats.MeanRate, stats.MaxDelta, stats.MaxStdDev)
+	}
+
+	r := internalResult{ID: id, Name: topic.Name, Windows: windows, Hz: &stats}
+	if len(windows) == 0 {
+		r.Message = fmt.Sprintf("%s | %s | %s", reason.hint(), cmd, tipList)
+		return r
+	}
+
+	if hasZero(windows) {
+		r.Message = fmt.Sprintf("%s | %s", cmd, tipList)
+		return r
+	}
+
+	if topic.MinRate > 0 || topic.MaxJitter > 0 || topic.MaxGap > 0 {
+		if !stats.hasTiming() {
+			// 只有 window 的旧版 pmupload 输出：阈值无从比较，按 window 判定通过并注明
+			r.OK = true
+			r.Message = tipList + " | pmupload 输出中没有频率/间隔数据，未检查频率、抖动和间隔阈值"
+			return r
+		}
+	}
+
+	var problems []string
+	if topic.MinRate > 0 && stats.MeanRate < topic.MinRate {
+		problems = append(problems, fmt.Sprintf("频率 %.2fHz 低于 %.2fHz", stats.MeanRate, topic.MinRate))
+	}
+	if topic.MaxJitter > 0 && stats.MaxStdDev > topic.MaxJitter {
+		problems = append(problems, fmt.Sprintf("抖动 %.4fs 超过 %.4fs", stats.MaxStdDev, topic.MaxJitter))
+	}
+	if topic.MaxGap > 0 && stats.MaxDelta > topic.MaxGap {
+		problems = append(problems, fmt.Sprintf("最大间隔 %.3fs 超过 %.3fs", stats.MaxDelta, topic.MaxGap))
+	}
+	if len(problems) > 0 {
+		r.Message = fmt.Sprintf("%s | %s | %s", strings.Join(problems, "，"), cmd, tipList)
+		return r
+	}
+
+	r.OK = true
+	r.Message = tipList
+	return r
+}
+
+// ---------- 车辆状态前置条件 ----------
+
+// VehicleState 一次读取到的车辆状态，Values 中的值已归一化（档位大写，开关量为 ON/OFF）
+type VehicleState struct {
+	Values   map[string]string
+	Err      string
+	Evidence []Evidence
+}
+
+// VEHICLE_STATE_LINE_RE 匹配 gear: D、brake_pedal=1、ignition: "ON" 形式的行
+var VEHICLE_STATE_LINE_RE = regexp.MustCompile(`^\s*([A-Za-z_][\w.]*)\s*[:=]\s*"?([^"\s,]+)"?`)
+
+// normalizeStateValue 归一化状态值：档位去掉 GEAR_ 前缀，其余状态的 1/true/pressed 等统一为 ON，0/false/released 等统一为 OFF
+func normalizeStateValue(name, v string) string {
+	v = strings.ToUpper(strings.TrimSpace(v))
+	if name == "gear" {
+		return strings.TrimPrefix(v, "GEAR_")
+	}
+	switch v {
+	case "1", "TRUE", "ON", "YES", "PRESSED", "APPLIED":
+		return "ON"
+	case "0", "FALSE", "OFF", "NO", "RELEASED":
+		return "OFF"
+	}
+	return v
+}
+
+// parseVehicleState 从命令输出中取出 names 对应的状态；字段名不区分大小写，也匹配 a.b.gear 的最后一段，同名字段取第一次出现的值
+func parseVehicleState(text string, names []string, fields map[string]string) map[string]string {
+	want := make(map[string]string) // 小写字段名 -> 状态名
+	for _, name := range names {
+		field := name
+		if f, ok := fields[name]; ok && f != "" {
+			field = f
+		}
+		want[strings.ToLower(field)] = name
+	}
+	values := make(map[string]string)
+	for _, line := range strings.Split(text, "\n") {
+		m := VEHICLE_STATE_LINE_RE.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		key := strings.ToLower(m[1])
+		if i := strings.LastIndex(key, "."); i >= 0 {
+			key = key[i+1:]
+		}
+		name, ok := want[key]
+		if !ok {
+			continue
+		}
+		if _, seen := values[name]; !seen {
+			values[name] = normalizeStateValue(name, m[2])
+		}
+	}
+	return values
+}
+
+// sortedStateNames 返回前置条件中的状态名（排序后，保证提示稳定）
+func sortedStateNames(requires map[string]string) []string {
+	var names []string
+	for name := range requires {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// formatRequires 把前置条件格式化为 "brake=on gear=D"
+func formatRequires(requires map[string]string) string {
+	var parts []string
+	for _, name := range sortedStateNames(requires) {
+		parts = append(parts, name+"="+requires[name])
+	}
+	return strings.Join(parts, " ")
+}
+
+// unmetPreconditions 返回未满足的条件，如 "gear=P"；读不到的状态记为 "gear=未知"
+func unmetPreconditions(requires, values map[string]string) []string {
+	var unmet []string
+	for _, name := range sortedStateNames(requires) {
+		got, ok := values[name]
+		if !ok {
+			unmet = append(unmet, name+"=未知")
+			continue
+		}
+		matched := false
+		for _, want := range strings.Split(requires[name], "|") {
+			if normalizeStateValue(name, want) == got {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			unmet = append(unmet, name+"="+got)
+		}
+	}
+	return unmet
+}
+
+// vehicleStateReader 一次检测内按需读取车辆状态，只读取一次并由各 Topic 共享
+type vehicleStateReader struct {
+	once  sync.Once
+	host  string
+	src   VehicleStateSource
+	names []string
+	state VehicleState
+}
+
+type vehicleStateKey struct{}
+
+// withVehicleState 返回携带车辆状态读取器的 ctx；状态在第一个需要它的 Topic 失败时才读取
+func withVehicleState(ctx context.Context, v VehicleConfig) context.Context {
+	r := &vehicleStateReader{host: v.MDC1.Host}
+	if v.State != nil {
+		r.src = *v.State
+		if r.src.Host != "" {
+			r.host = r.src.Host
+		}
+	}
+	if r.src.Cmd == "" {
+		r.src.Cmd = DEFAULT_VEHICLE_STATE_CMD
+	}
+	seen := make(map[string]bool)
+	for _, mdc := range []MDCConfig{v.MDC1, v.MDC2} {
+		for _, t := range mdc.Topics {
+			for _, name := range sortedStateNames(t.Requires) {
+				if !seen[name] {
+					seen[name] = true
+					r.names = append(r.names, name)
+				}
+			}
+		}
+	}
+	return context.WithValue(ctx, vehicleStateKey{}, r)
+}
+
+// vehicleState 读取（或返回已读取的）车辆状态；ctx 中没有读取器时返回 nil
+func vehicleState(ctx context.Context) *VehicleState {
+	r, ok := ctx.Value(vehicleStateKey{}).(*vehicleStateReader)
+	if !ok {
+		return nil
+	}
+	r.once.Do(func() {
+		log := &evidenceLog{scope: "vehicle_state"}
+		defer func() { r.state.Evidence = log.list() }()
+		client, err := dialHost(r.host, log, 1)
+		if err != nil {
+			r.state.Err = fmt.Sprintf("SSH 连接 %s 失败: %v", r.host, err)
+			return
+		}
+		defer client.Close()
+		_, out, errOut, err := execCmd(client, r.src.Cmd, PMUPLOAD_TIMEOUT)
+		r.state.Values = parseVehicleState(out+"\n"+errOut, r.names, r.src.Fields)
+		if len(r.state.Values) == 0 {
+			r.state.Err = "输出中没有 " + strings.Join(r.names, "/") + " 字段"
+			if err != nil {
+				r.state.Err = err.Error()
+			}
+		}
+	})
+	return &r.state
+}
+
+// applyPreconditions Topic 未发布时核对车辆状态：不满足前置条件时改为报告前置条件未满足，
+// 满足时说明已排除车辆状态原因，读取失败时提示该 Topic 依赖的状态
+func applyPreconditions(ctx context.Context, r *internalResult, topic TopicCmd) {
+	want := formatRequires(topic.Requires)
+	st := vehicleState(ctx)
+	if st == nil || ctx.Err() != nil {
+		r.Message = fmt.Sprintf("该 Topic 要求车辆状态 %s | %s", want, r.Message)
+		return
+	}
+	r.Evidence = append(r.Evidence, st.Evidence...)
+	if st.Err != "" {
+		r.Message = fmt.Sprintf("车辆状态读取失败（%s），该 Topic 要求 %s | %s", st.Err, want, r.Message)
+		return
+	}
+	if unmet := unmetPreconditions(topic.Requires, st.Values); len(unmet) > 0 {
+		r.Precondition = strings.Join(unmet, " ")
+		r.Message = fmt.Sprintf("precondition not met: %s（要求 %s），请驾驶员调整车辆状态后复检 | %s", r.Precondition, want, r.Message)
+		return
+	}
+	r.Message = fmt.Sprintf("车辆状态满足 %s，非车辆状态原因 | %s", want, r.Message)
+}
+
+// ---------- Topic 内容抽检 ----------
+
+// ContentProbe Topic 内容抽检配置：用 pmupload echo 采几条消息，按配置的断言检查内容。
+// 只在 hz 检测通过后执行，未配置的断言不检查。
+type ContentProbe struct {
+	Cmd                string `json:"cmd,omitempty"`                 // 默认 "timeout 8s pmupload adstopic echo <topic> -n <count>"
+	Count              int    `json:"count,omitempty"`               // 采样消息数，默认 CONTENT_PROBE_COUNT
+	NonEmpty           bool   `json:"non_empty,omitempty"`           // 每条消息的 data 不为空
+	MinPoints          int    `json:"min_points,omitempty"`          // 每条消息的点数不少于该值
+	TimestampAdvancing bool   `json:"timestamp_advancing,omitempty"` // 相邻消息的 header 时间戳递增（帧未冻结）
+	FrameID            string `json:"frame_id,omitempty"`            // header.frame_id 必须等于该值
+}
+
+// echoMessage pmupload echo 输出中的一条消息，未出现的字段对应的 has* 为 false
+type echoMessage struct {
+	Stamp        float64 `json:"stamp"` // 秒
+	HasStamp     bool    `json:"has_stamp"`
+	FrameID      string  `json:"frame_id"`
+	HasFrameID   bool    `json:"has_frame_id"`
+	Points       int     `json:"points"`
+	HasPoints    bool    `json:"has_points"`
+	PayloadBytes int     `json:"payload_bytes"` // data 字段的元素数/长度，-1 表示没有 data 字段
+}
+
+// ContentResult 内容抽检结果
+type ContentResult struct {
+	Cmd      string    `json:"cmd"`
+	Messages int       `json:"messages"`
+	Points   []int     `json:"points,omitempty"`
+	Stamps   []float64 `json:"stamps,omitempty"`
+	FrameIDs []string  `json:"frame_ids,omitempty"`
+	Problems []string  `json:"problems,omitempty"`
+}
+
+var (
+	ECHO_STAMP_RE    = regexp.MustCompile(`^(stamp|timestamp|time_stamp)\s*:\s*([0-9]+(?:\.[0-9]+)?)\s*$`)
+	ECHO_SECS_RE     = regexp.MustCompile(`^(secs|sec)\s*:\s*([0-9]+)\s*$`)
+	ECHO_NSECS_RE    = regexp.MustCompile(`^(nsecs|nanosec|nsec)\s*:\s*([0-9]+)\s*$`)
+	ECHO_FRAME_RE    = regexp.MustCompile(`^frame_id\s*:\s*["']?([^"'\s]*)["']?\s*$`)
+	ECHO_POINTS_RE   = regexp.MustCompile(`^(point_num|points_num|num_points|point_count|points_count|width|height)\s*:\s*([0-9]+)\s*$`)
+	ECHO_DATA_RE     = regexp.MustCompile(`^data\s*:\s*(.*)$`)
+	ECHO_DATA_LEN_RE = regexp.MustCompile(`length\s*:\s*([0-9]+)`)
+)
+
+// stampSeconds 把整数形式的毫秒/微秒/纳秒时间戳换算为秒
+func stampSeconds(v float64) float64 {
+	switch {
+	case v > 1e17:
+		return v / 1e9
+	case v > 1e14:
+		return v / 1e6
+	case v > 1e11:
+		return v / 1e3
+	}
+	return v
+}
+
+// dataLength 估算 data 字段的长度：[] / "" 为 0，<array ... length: N> 为 N，列表为元素数，其余为字符数
+func dataLength(v string) int {
+	v = strings.TrimSpace(v)
+	if m := ECHO_DATA_LEN_RE.FindStringSubmatch(v); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		return n
+	}
+	switch v {
+	case "", "[]", `""`, "''", "b''":
+		return 0
+	}
+	if strings.HasPrefix(v, "[") && strings.HasSuffix(v, "]") {
+		return strings.Count(v, ",") + 1
+	}
+	return len(v)
+}
+
+// parseEchoMessages 解析 pmupload echo 的输出（ROS 风格，消息之间以 --- 分隔），
+// 每条消息只取第一个时间戳（header.stamp）、frame_id、点数字段和 data 字段
+func parseEchoMessages(text string) []echoMessage {
+	var msgs []echoMessage
+	var cur echoMessage
+	var secs, nsecs float64
+	var hasSecs, hasNsecs, hasContent bool
+	width, height := -1, -1
+
+	reset := func() {
+		cur = echoMessage{PayloadBytes: -1}
+		secs, nsecs, hasSecs, hasNsecs, hasContent = 0, 0, false, false, false
+		width, height = -1, -1
+	}
+	flush := func() {
+		if !hasContent {
+			return
+		}
+		if !cur.HasStamp && hasSecs {
+			cur.Stamp, cur.HasStamp = secs+nsecs/1e9, true
+		}
+		if !cur.HasPoints && width >= 0 {
+			cur.Points, cur.HasPoints = width, true
+			if height >= 0 {
+				cur.Points *= height
+			}
+		}
+		msgs = append(msgs, cur)
+	}
+
+	reset()
+	for _, raw := range strings.Split(text, "\n") {
+		line := strings.TrimSpace(strings.TrimRight(raw, "\r"))
+		if line == "---" {
+			flush()
+			reset()
+			continue
+		}
+		if line == "" || !strings.Contains(line, ":") {
+			continue
+		}
+		// 只有出现消息字段时才算一条消息，避免把提示信息（如 WARNING: ...）当作消息
+		if strings.HasPrefix(line, "header:") {
+			hasContent = true
+		}
+
+		if m := ECHO_STAMP_RE.FindStringSubmatch(line); m != nil && !cur.HasStamp && !hasSecs {
+			v, _ := strconv.ParseFloat(m[2], 64)
+			cur.Stamp, cur.HasStamp = stampSeconds(v), true
+			hasContent = true
+		} else if m := ECHO_SECS_RE.FindStringSubmatch(line); m != nil && !hasSecs && !cur.HasStamp {
+			secs, _ = strconv.ParseFloat(m[2], 64)
+			hasSecs = true
+			hasContent = true
+		} else if m := ECHO_NSECS_RE.FindStringSubmatch(line); m != nil && !hasNsecs && hasSecs {
+			nsecs, _ = strconv.ParseFloat(m[2], 64)
+			hasNsecs = true
+		} else if m := ECHO_FRAME_RE.FindStringSubmatch(line); m != nil && !cur.HasFrameID {
+			cur.FrameID, cur.HasFrameID = m[1], true
+			hasContent = true
+		} else if m := ECHO_POINTS_RE.FindStringSubmatch(line); m != nil {
+			n, _ := strconv.Atoi(m[2])
+			hasContent = true
+			switch m[1] {
+			case "width":
+				if width < 0 {
+					width = n
+				}
+			case "height":
+				if height < 0 {
+					height = n
+				}
+			default:
+				if !cur.HasPoints {
+					cur.Points, cur.HasPoints = n, true
+				}
+			}
+		} else if m := ECHO_DATA_RE.FindStringSubmatch(line); m != nil && cur.PayloadBytes < 0 {
+			cur.PayloadBytes = dataLength(m[1])
+			hasContent = true
+		}
+	}
+	flush()
+	return msgs
+}
+
+// probeCmd 返回抽检命令
+func (p ContentProbe) probeCmd(topic string) string {
+	if p.Cmd != "" {
+		return p.Cmd
+	}
+	count := p.Count
+	if count <= 0 {
+		count = CONTENT_PROBE_COUNT
+	}
+	return fmt.Sprintf("%s %s -n %d", PMUPLOAD_ECHO_CMD, topic, count)
+}
+
+// evaluateContent 按断言检查采到的消息，返回抽检结果（Problems 为空表示通过）
+func evaluateContent(p ContentProbe, cmd string, msgs []echoMessage) ContentResult {
+	cr := ContentResult{Cmd: cmd, Messages: len(msgs)}
+	if len(msgs) == 0 {
+		cr.Problems = append(cr.Problems, "未采到消息")
+		return cr
+	}
+
+	var lastStamp float64
+	hasLast := false
+	for i, m := range msgs {
+		n := i + 1
+		if m.HasPoints {
+			cr.Points = append(cr.Points, m.Points)
+		}
+		if m.HasStamp {
+			cr.Stamps = append(cr.Stamps, m.Stamp)
+		}
+		if m.HasFrameID {
+			cr.FrameIDs = append(cr.FrameIDs, m.FrameID)
+		}
+
+		if p.NonEmpty && (m.PayloadBytes == 0 || (m.HasPoints && m.Points == 0)) {
+			cr.Problems = append(cr.Problems, fmt.Sprintf("第 %d 条消息内容为空", n))
+		}
+		if p.MinPoints > 0 {
+			if !m.HasPoints {
+				cr.Problems = append(cr.Problems, fmt.Sprintf("第 %d 条消息没有点数字段", n))
+			} else if m.Points < p.MinPoints {
+				cr.Problems = append(cr.Problems, fmt.Sprintf("第 %d 条消息点数 %d 少于 %d", n, m.Points, p.MinPoints))
+			}
+		}
+		if p.FrameID != "" && m.FrameID != p.FrameID {
+			cr.Problems = append(cr.Problems, fmt.Sprintf("第 %d 条消息 frame_id=%q，期望 %q", n, m.FrameID, p.FrameID))
+		}
+		if p.TimestampAdvancing && m.HasStamp {
+			if hasLast && m.Stamp <= lastStamp {
+				cr.Problems = append(cr.Problems, fmt.Sprintf("第 %d 条消息时间戳 %.3f 未前进，疑似帧冻结", n, m.Stamp))
+			}
+			lastStamp, hasLast = m.Stamp, true
+		}
+	}
+	if p.TimestampAdvancing && len(cr.Stamps) < 2 {
+		cr.Problems = append(cr.Problems, fmt.Sprintf("带时间戳的消息只有 %d 条，无法判断时间戳是否前进", len(cr.Stamps)))
+	}
+	return cr
+}
+
+// runContentProbe 在已有连接上执行抽检命令并检查消息内容
+func runContentProbe(client *sshConn, topic TopicCmd) (ContentResult, string) {
+	p := *topic.Probe
+	cmd := p.probeCmd(topicOfCmd(topic.Cmd))
+	_, out, errOut, err := execCmd(client, cmd, PMUPLOAD_TIMEOUT)
+	cr := evaluateContent(p, cmd, parseEchoMessages(out))
+	if err != nil {
+		cr.Problems = append([]string{fmt.Sprintf("抽检命令执行失败: %v", err)}, cr.Problems...)
+	}
+	merged := out
+	if out != "" && errOut != "" {
+		merged += "\n"
+	}
+	return cr, merged + errOut
+}
+
+// ---------- Topic 时延检测 ----------
+
+// LatencyCheck Topic 端到端时延检测配置：采样消息到达 MDC 的时间与其 header 时间戳之差，
+// 与 hz 检测复用同一条 SSH 连接，只在 hz 检测通过后执行
+type LatencyCheck struct {
+	Count     int     `json:"count,omitempty"`              // 采样消息数，默认 LATENCY_SAMPLE_COUNT
+	BudgetP50 float64 `json:"budget_p50_seconds,omitempty"` // p50 时延上限，0 表示不检查
+	BudgetP95 float64 `json:"budget_p95_seconds,omitempty"` // p95 时延上限，0 表示不检查
+}
+
+// LatencyResult 时延检测结果，时延单位为秒
+type LatencyResult struct {
+	Cmd     string    `json:"cmd"`
+	Samples []float64 `json:"samples"`
+	P50     float64   `json:"p50_seconds"`
+	P95     float64   `json:"p95_seconds"`
+	Max     float64   `json:"max_seconds"`
+	Problem string    `json:"problem,omitempty"`
+
+	LineBuffered bool `json:"line_buffered"` // pmupload 是否经 stdbuf -oL 按行输出；否则块缓冲会使时延偏大
+}
+
+// LATENCY_RECV_RE 时延命令在每条消息结束（---）时输出的 MDC 本地时间
+var LATENCY_RECV_RE = regexp.MustCompile(`^@recv\s+([0-9]+(?:\.[0-9]+)?)`)
+
+// LATENCY_LINEBUF_MARK MDC 上有 stdbuf、pmupload 以行缓冲运行时，时延命令在输出开头打印的标记
+const LATENCY_LINEBUF_MARK = "@linebuf"
+
+// latencyCmd 在每条消息的分隔行前插入 "@recv <MDC 当前时间>"，用于与 header 时间戳比较。
+// pmupload 的 stdout 接管道时为块缓冲，消息会攒满缓冲区才被读到，因此有 stdbuf 时以 -oL 运行。
+func latencyCmd(topic string, count int) string {
+	if count <= 0 {
+		count = LATENCY_SAMPLE_COUNT
+	}
+	return fmt.Sprintf(`if command -v stdbuf >/dev/null 2>&1; then echo %s; B="stdbuf -oL"; fi; $B %s %s -n %d | while IFS= read -r l; do case "$l" in ---*) echo "@recv $(date +%%s.%%N)";; esac; printf '%%s\n' "$l"; done`,
+		LATENCY_LINEBUF_MARK, PMUPLOAD_ECHO_CMD, topic, count)
+}
+
+// parseLatencySamples 按 @recv 行切分消息，返回每条带时间戳消息的时延
+func parseLatencySamples(text string) []float64 {
+	var samples []float64
+	var block []string
+	for _, raw := range strings.Split(text, "\n") {
+		line := strings.TrimRight(raw, "\r")
+		m := LATENCY_RECV_RE.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			block = append(block, line)
+			continue
+		}
+		recv, _ := strconv.ParseFloat(m[1], 64)
+		if msgs := parseEchoMessages(strings.Join(block, "\n")); len(msgs) == 1 && msgs[0].HasStamp {
+			// 时间戳为 1e9 量级，相减后保留到微秒，去掉浮点误差
+			samples = append(samples, math.Round((recv-msgs[0].Stamp)*1e6)/1e6)
+		}
+		block = nil
+	}
+	return samples
+}
+
+// percentile 按最近秩法取分位数，values 不要求有序
+func percentile(values []float64, p float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	rank := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	return sorted[rank]
+}
+
+// evaluateLatency 统计时延分位数并按预算判定
+func evaluateLatency(lc LatencyCheck, cmd string, samples []float64) LatencyResult {
+	lr := LatencyResult{Cmd: cmd, Samples: samples}
+	if lr.Samples == nil {
+		lr.Samples = []float64{}
+	}
+	if len(samples) == 0 {
+		lr.Problem = "未采到带时间戳的消息"
+		return lr
+	}
+	lr.P50 = percentile(samples, 50)
+	lr.P95 = percentile(samples, 95)
+	lr.Max = percentile(samples, 100)
+
+	var problems []string
+	if lc.BudgetP50 > 0 && lr.P50 > lc.BudgetP50 {
+		problems = append(problems, fmt.Sprintf("时延 p50 %.3fs 超过 %.3fs", lr.P50, lc.BudgetP50))
+	}
+	if lc.BudgetP95 > 0 && lr.P95 > lc.BudgetP95 {
+		problems = append(problems, fmt.Sprintf("时延 p95 %.3fs 超过 %.3fs", lr.P95, lc.BudgetP95))
+	}
+	lr.Problem = strings.Join(problems, "，")
+	return lr
+}
+
+// runLatencyCheck 在已有连接上采样消息并统计时延
+func runLatencyCheck(client *sshConn, topic TopicCmd) (LatencyResult, string) {
+	lc := *topic.Latency
+	cmd := latencyCmd(topicOfCmd(topic.Cmd), lc.Count)
+	_, out, errOut, err := execCmd(client, cmd, PMUPLOAD_TIMEOUT)
+	body, lineBuffered := strings.CutPrefix(out, LATENCY_LINEBUF_MARK+"\n")
+	lr := evaluateLatency(lc, cmd, parseLatencySamples(body))
+	lr.LineBuffered = lineBuffered
+	if err != nil && len(lr.Samples) == 0 {
+		lr.Problem = fmt.Sprintf("时延采样命令执行失败: %v", err)
+	}
+	merged := out
+	if out != "" && errOut != "" {
+		merged += "\n"
+	}
+	return lr, merged + errOut
+}
+
+func runPmuploadGroup(ctx context.Context, host string, items []TopicCmd, startID, maxWorkers int, selected map[int]bool) []internalResult {
+	var batched []internalResult
+	done := make(map[int]bool)
+	if batchEnabled {
+		batched = runPmuploadBatch(ctx, host, items, startID, selected)
+		for _, r := range batched {
+			done[r.ID] = true
+		}
+	}
+
+	var results []internalResult
+	var mu sync.Mutex
+	sem := make(chan struct{}, maxWorkers)
+	var wg sync.WaitGroup
+
+	for i, item := range items {
+		id := startID + i
+		if (selected != nil && !selected[id]) || done[id] {
+			continue
+		}
+		wg.Add(1)
+		go func(id int, topic TopicCmd) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			if ctx.Err() != nil {
+				return
+			}
+
+			start := time.Now()
+			result := pmuploadCheck(ctx, id, host, topic, nil)
+			result.Duration = time.Since(start).Seconds()
+			result.Host = host
+			// 可能要串行重跑的项等重跑与否确定后再结束，避免同一项发出两次 check_finished
+			if maxWorkers == 1 || !concurrencyStarved(result) {
+				result = finishCheck(ctx, result)
+			}
+			mu.Lock()
+			results = append(results, result)
+			mu.Unlock()
+		}(id, item)
+	}
+
+	wg.Wait()
+
+	// 同组其他 topic 并发时能通过，而某项连接成功却没有任何输出，可能是并发过高导致的，逐个串行重跑确认；
+	// 全组都失败（车辆或 pmupload 本身有问题）时不重跑
+	succeeded := 0
+	for _, r := range results {
+		if r.OK {
+			succeeded++
+		}
+	}
+	byID := make(map[int]TopicCmd)
+	for i, item := range items {
+		byID[startID+i] = item
+	}
+	for i, r := range results {
+		if maxWorkers == 1 || !concurrencyStarved(r) {
+			continue
+		}
+		if succeeded > 0 && ctx.Err() == nil {
+			r = rerunSerially(ctx, host, byID[r.ID], r, maxWorkers)
+		}
+		results[i] = finishCheck(ctx, r)
+	}
+	return append(batched, results...)
+}
+
+// concurrencyStarved 报告失败项是否像是被并发挤掉：连接成功但 pmupload 没有任何输出。
+// SSH 连接失败时输出为 "SSH 连接失败: ..."，pmupload 报错时也有输出，都不算
+func concurrencyStarved(r internalResult) bool {
+	return !r.OK && len(r.Windows) == 0 && r.Precondition == "" && strings.TrimSpace(r.Output) == ""
+}
+
+// rerunSerially 在组内其余检测结束后单独重跑 first 这一项，并记录是否为并发导致的失败。
+// 执行次数接着 first 编号，命令记录和耗时包含两次执行；不发出 check_finished，由调用方结束
+func rerunSerially(ctx context.Context, host string, topic TopicCmd, first internalResult, maxWorkers int) internalResult {
+	emit(ctx, Event{Type: EVENT_REMEDIATION_APPLIED, ID: first.ID, Name: topic.Name,
+		Message: fmt.Sprintf("并发 %d 时无输出，串行重跑", maxWorkers)})
+
+	start := time.Now()
+	r := pmuploadCheck(ctx, first.ID, host, topic, &first)
+	r.Duration = first.Duration + time.Since(start).Seconds()
+	r.Host = host
+	r.SerialRetry = true
+	if r.OK {
+		r.Remediation = append(r.Remediation, fmt.Sprintf("并发 %d 时无输出，串行重跑通过", maxWorkers))
+		r.Message += fmt.Sprintf(" | 并发 %d 时无输出、串行重跑通过，建议调低 %s 的 max_workers", maxWorkers, host)
+	} else {
+		r.Remediation = append(r.Remediation, fmt.Sprintf("并发 %d 时无输出，串行重跑仍失败", maxWorkers))
+	}
+	return r
+}
+
+// batchEnabled 为 true 时同主机的 Topic 合并为一次 pmupload 调用（-batch）。默认关闭：
+// 并非所有 MDC 上的 pmupload 都支持一次监听多个 topic，确认支持的车辆再开启
+var batchEnabled bool
+
+// batchUnsupported 记录不支持一次监听多个 topic 的主机，之后的检测直接逐个执行
+var batchUnsupported sync.Map
+
+func addBatchFlag(fs *flag.FlagSet) {
+	fs.BoolVar(&batchEnabled, "batch", false, "同主机的 Topic 合并为一次 pmupload 调用（需 pmupload 支持一次监听多个 topic，默认逐个执行）")
+}
+
+// batchTopics 挑出可以合并执行的项：使用标准 hz 命令且 topic 不重复，返回 topic 列表及其在 items 中的下标
+func batchTopics(items []TopicCmd, startID int, selected map[int]bool) ([]string, map[string]int) {
+	var topics []string
+	index := make(map[string]int)
+	for i, item := range items {
+		if selected != nil && !selected[startID+i] {
+			continue
+		}
+		t := topicOfCmd(item.Cmd)
+		if t == "" || item.Cmd != PMUPLOAD_HZ_CMD+" "+t {
+			continue // 自定义命令仍单独执行
+		}
+		if _, dup := index[t]; dup {
+			continue
+		}
+		index[t] = i
+		topics = append(topics, t)
+	}
+	return topics, index
+}
+
+// runPmuploadBatch 在一次 pmupload 中同时监听主机上的全部 topic，按 topic 拆分输出后判定。
+// 只返回通过的项；未出现在输出中或判定失败的项由调用方逐个单独执行，失败结论始终来自单独执行。
+// 输出中没有第一个以外 topic 的采样（包括没有任何输出）时认为 pmupload 不支持多 topic，
+// 记录后本进程不再对该主机合并执行。
+func runPmuploadBatch(ctx context.Context, host string, items []TopicCmd, startID int, selected map[int]bool) []internalResult {
+	if _, unsupported := batchUnsupported.Load(host); unsupported || ctx.Err() != nil {
+		return nil
+	}
+	topics, index := batchTopics(items, startID, selected)
+	if len(topics) < 2 {
+		return nil
+	}
+
+	start := time.Now()
+	log := &evidenceLog{scope: "batch"}
+	cmd := PMUPLOAD_HZ_CMD + " " + strings.Join(topics, " ")
+	client, stats, output := runPmuploadOnce(host, cmd, log, 1)
+	if client == nil {
+		return nil
+	}
+	defer client.Close()
+
+	byTopic := make(map[string][]HzSample)
+	for _, s := range stats.Samples {
+		byTopic[s.Topic] = append(byTopic[s.Topic], s)
+	}
+	others := 0
+	for _, t := range topics[1:] {
+		others += len(byTopic[t])
+	}
+	if others == 0 {
+		batchUnsupported.Store(host, true)
+		return nil
+	}
+
+	var results []internalResult
+	for _, t := range topics {
+		if ctx.Err() != nil {
+			break
+		}
+		i := index[t]
+		id, topic := startID+i, items[i]
+		ts := summarizeHz(byTopic[t])
+		windows := ts.windows()
+		if len(windows) == 0 || hasZero(windows) {
+			continue
+		}
+		r := pmuploadVerdict(id, topic, ts, NO_SAMPLE_SILENT)
+		if !r.OK {
+			continue
+		}
+
+		emitCheckStarted(ctx, id, topic.Name)
+		ok := true
+		emit(ctx, Event{Type: EVENT_CHECK_ATTEMPT, ID: id, Name: topic.Name, Attempt: 1, OK: &ok,
+			Message: fmt.Sprintf("windows=%v（合并执行）", windows)})
+		// 内容抽检和时延检测复用合并执行的连接，证据单独记录
+		topicLog := &evidenceLog{}
+		conn := *client
+		conn.log = topicLog
+		out := runTopicFollowUps(ctx, &conn, topic, &r, output)
+		r.Output = redactSecrets(out)
+		r.Evidence = append(log.list(), topicLog.list()...)
+		r.Duration = time.Since(start).Seconds()
+		r.Host = host
+		r.Batched = true
+		results = append(results, finishCheck(ctx, r))
+	}
+	return results
+}
+
+// ---------- 检测逻辑 ----------
+// runCheck 执行检测；ctx 取消后不再启动新的检测项，返回已完成部分并标记为已取消
+func runCheck(ctx context.Context, v VehicleConfig, selected map[int]bool) CheckResult {
+	startTime := time.Now()
+	var items []internalResult
+	emit(ctx, Event{Type: EVENT_RUN_STARTED})
+	ctx = withVehicleState(ctx, v)
+
+	// 1. 车机状态（必须先检测，只有成功后才继续）
+	if selected == nil || selected[1] {
+		emitCheckStarted(ctx, 1, "车机状态")
+	}
+	carStart := time.Now()
+	carOK := true
+	carLog := &evidenceLog{scope: "1"}
+	sshLatency := make(map[string]float64)
+	for _, h := range v.Hosts {
+		start := time.Now()
+		if !trySSH(h, carLog) {
+			carOK = false
+			break
+		}
+		sshLatency[h] = time.Since(start).Seconds()
+	}
+
+	carDuration := time.Since(carStart).Seconds()
+
+	// 无论用户是否选择检测项1，都记录车机状态结果
+	if selected == nil || selected[1] {
+		if carOK {
+			items = append(items, finishCheck(ctx, internalResult{ID: 1, Name: "车机状态", OK: true, Duration: carDuration, Evidence: carLog.list()}))
+		} else {
+			items = append(items, finishCheck(ctx, internalResult{ID: 1, Name: "车机状态", OK: false, Message: "请上电或插上网线", Duration: carDuration, Evidence: carLog.list()}))
+		}
+	} else if !carOK {
+		// 用户没选择检测项1，但车机连不上，也要显示失败原因
+		items = append(items, finishCheck(ctx, internalResult{ID: 1, Name: "车机状态", OK: false, Message: "请上电或插上网线（前置检测失败）", Duration: carDuration, Evidence: carLog.list()}))
+	}
+
+	// 如果车机状态失败，直接返回，不继续后面的检测
+	if !carOK {
+		return finishRun(ctx, startTime, items, sshLatency)
+	}
+
+	// 2. MDC1A 挂载
+	if (selected == nil || selected[2]) && ctx.Err() == nil {
+		start := time.Now()
+		row2 := checkMountRow(ctx, 2, fmt.Sprintf("%s %s", v.MDC1.Host, v.MDC1.Name), v.MDC1.Host, v.MDC1.MountTargets)
+		row2.Duration = time.Since(start).Seconds()
+		items = append(items, row2)
+	}
+
+	// 3. MDC2 挂载
+	if (selected == nil || selected[3]) && ctx.Err() == nil {
+		start := time.Now()
+		row3 := checkMountRow(ctx, 3, fmt.Sprintf("%s %s", v.MDC2.Host, v.MDC2.Name), v.MDC2.Host, v.MDC2.MountTargets)
+		row3.Duration = time.Since(start).Seconds()
+		items = append(items, row3)
+	}
+
+	// 4-9. MDC1 Topics
+	start1, start2 := v.topicStartIDs()
+	mdc1Results := runPmuploadGroup(ctx, v.MDC1.Host, v.MDC1.Topics, start1, v.MDC1.workers(), selected)
+	items = append(items, mdc1Results...)
+
+	// 10-13. MDC2 Topics
+	mdc2Results := runPmuploadGroup(ctx, v.MDC2.Host, v.MDC2.Topics, start2, v.MDC2.workers(), selected)
+	items = append(items, mdc2Results...)
+
+	// 14-15. Topic 目录比对：只在 -items=catalog 或显式指定 ID 时执行，不计入默认的全量检测
+	cat1, cat2 := v.catalogIDs()
+	for _, c := range []struct {
+		id  int
+		mdc MDCConfig
+	}{{cat1, v.MDC1}, {cat2, v.MDC2}} {
+		if selected[c.id] && ctx.Err() == nil {
+			start := time.Now()
+			row := checkTopicCatalog(ctx, c.id, c.mdc)
+			row.Duration = time.Since(start).Seconds()
+			items = append(items, row)
+		}
+	}
+
+	return finishRun(ctx, startTime, items, sshLatency)
+}
+
+// finishRun 汇总结果并发出 run_finished 事件；ctx 已取消时标记为已取消
+func finishRun(ctx context.Context, startTime time.Time, items []internalResult, sshLatency map[string]float64) CheckResult {
+	result := buildResult(startTime, items)
+	result.SSHConnect = sshLatency
+	if ctx.Err() != nil {
+		result.Cancelled = true
+		result.Success = false
+	}
+	ok := result.Success
+	emit(ctx, Event{Type: EVENT_RUN_FINISHED, OK: &ok, Result: &result})
+	return result
+}
+
+// buildResult 构建返回结果
+func buildResult(startTime time.Time, items []internalResult) CheckResult {
+	// 分类到 passed、failed 和 skipped：车辆状态前置条件未满足的项无法判定传感器好坏，单独列出，不影响整体结果
+	passed := make(map[string]ResultItem)
+	failed := make(map[string]ResultItem)
+	skipped := make(map[string]ResultItem)
+
+	for _, item := range items {
+		key := strconv.Itoa(item.ID)
+		ri := ResultItem{
+			Name:     item.Name,
+			Message:  item.Message,
+			Windows:  item.Windows,
+			Hz:       item.Hz,
+			Content:  item.Content,
+			Latency:  item.Latency,
+			Target:   item.Target,
+			AvailGB:  item.AvailGB,
+			Duration: item.Duration,
+			Host:     item.Host,
+
+			SerialRetry:  item.SerialRetry,
+			Batched:      item.Batched,
+			Precondition: item.Precondition,
+			Output:       item.Output,
+			Remediation:  item.Remediation,
+			Evidence:     item.Evidence,
+		}
+		switch {
+		case item.OK:
+			passed[key] = ri
+		case item.Precondition != "":
+			skipped[key] = ri
+		default:
+			failed[key] = ri
+		}
+	}
+
+	duration := time.Since(startTime).Seconds()
+
+	result := CheckResult{
+		Timestamp:    time.Now().Format(time.RFC3339),
+		Success:      len(failed) == 0,
+		Duration:     duration,
+		Passed:       passed,
+		Failed:       failed,
+		FailedCount:  len(failed),
+		SkippedCount: len(skipped),
+		TotalCount:   len(items),
+	}
+	if len(skipped) > 0 {
+		result.Skipped = skipped
+	}
+	return result
+}
+
+// ---------- 输出格式 (junit / tap) ----------
+
+// 检测项分类，与 /api/items 的 category 一致
+var CATEGORIES = []string{"car", "mount", "topic"}
+
+func itemCategory(id int) string {
+	switch {
+	case id == 1:
+		return "car"
+	case id <= 3:
+		return "mount"
+	default:
+		return "topic"
+	}
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Body    string `xml:",chardata"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr"`
+}
+
+type junitTestCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+func secondsAttr(sec float64) string {
+	return strconv.FormatFloat(sec, 'f', 3, 64)
+}
+
+// writeJUnit 以 JUnit XML 输出结果：每个检测项一个 testcase，按 car/mount/topic 分 testsuite
+func writeJUnit(w io.Writer, result CheckResult) error {
+	ids, items, ok := resultItems(result)
+	doc := junitTestSuites{
+		Name:     "check_car",
+		Tests:    result.TotalCount,
+		Failures: result.FailedCount,
+		Skipped:  result.SkippedCount,
+		Time:     secondsAttr(result.Duration),
+	}
+
+	for _, cat := range CATEGORIES {
+		suite := junitTestSuite{Name: cat, Timestamp: result.Timestamp}
+		var total float64
+		for _, id := range ids {
+			if itemCategory(id) != cat {
+				continue
+			}
+			it := items[id]
+			tc := junitTestCase{
+				ClassName: "check_car." + cat,
+				Name:      fmt.Sprintf("%d. %s", id, it.Name),
+				Time:      secondsAttr(it.Duration),
+			}
+			switch {
+			case ok[id]:
+			case itemSkipped(it):
+				tc.Skipped = &junitSkipped{Message: it.Message}
+				suite.Skipped++
+			default:
+				tc.Failure = &junitFailure{Message: it.Message, Body: it.Message}
+				suite.Failures++
+			}
+			suite.TestCases = append(suite.TestCases, tc)
+			suite.Tests++
+			total += it.Duration
+		}
+		if suite.Tests == 0 {
+			continue
+		}
+		suite.Time = secondsAttr(total)
+		doc.Suites = append(doc.Suites, suite)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// writeTAP 以 TAP version 13 输出结果，失败项附带 YAML 诊断信息
+func writeTAP(w io.Writer, result CheckResult) error {
+	ids, items, ok := resultItems(result)
+	var b strings.Builder
+	b.WriteString("TAP version 13\n")
+	fmt.Fprintf(&b, "1..%d\n", len(ids))
+
+	lastCat := ""
+	for n, id := range ids {
+		it := items[id]
+		if cat := itemCategory(id); cat != lastCat {
+			fmt.Fprintf(&b, "# %s\n", cat)
+			lastCat = cat
+		}
+		switch {
+		case ok[id]:
+			fmt.Fprintf(&b, "ok %d - %d. %s\n", n+1, id, it.Name)
+		case itemSkipped(it):
+			fmt.Fprintf(&b, "ok %d - %d. %s # SKIP precondition not met: %s\n", n+1, id, it.Name, it.Precondition)
+		default:
+			fmt.Fprintf(&b, "not ok %d - %d. %s\n", n+1, id, it.Name)
+		}
+		b.WriteString("  ---\n")
+		fmt.Fprintf(&b, "  duration_ms: %d\n", int(it.Duration*1000))
+		if it.Message != "" {
+			msg, _ := json.Marshal(it.Message) // JSON 字符串即合法的 YAML 双引号字符串
+			fmt.Fprintf(&b, "  message: %s\n", msg)
+		}
+		b.WriteString("  ...\n")
+	}
+	if result.Cancelled {
+		b.WriteString("Bail out! 检测已取消\n")
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// ---------- HTML 报告 (-report) ----------
+
+// reportMeta HTML 报告中的检测信息
+type reportMeta struct {
+	Vehicle   string
+	Operator  string
+	Items     string
+	HistoryID string
+	Station   string // 运行检测的电脑
+}
+
+type reportRow struct {
+	ID       int
+	Category string
+	OK       bool
+	Skipped  bool
+	Item     ResultItem
+}
+
+var REPORT_TEMPLATE = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="utf-8">
+<title>车辆采集前检测报告 {{.Meta.Vehicle}} {{.Result.Timestamp}}</title>
+<style>
+body { font-family: -apple-system, "Microsoft YaHei", "PingFang SC", sans-serif; margin: 24px; color: #222; }
+h1 { font-size: 20px; margin: 0 0 12px; }
+h2 { font-size: 16px; margin: 24px 0 8px; }
+.summary { padding: 10px 14px; border-radius: 4px; font-weight: bold; margin-bottom: 12px; }
+.summary.ok { background: #e6f6e6; color: #1a7f1a; }
+.summary.fail { background: #fdeaea; color: #c62828; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ddd; padding: 6px 10px; text-align: left; vertical-align: top; }
+th { background: #f5f5f5; }
+table.meta th { width: 90px; }
+.ok-mark { color: #1a9c1a; font-weight: bold; }
+.fail-mark { color: #d32f2f; font-weight: bold; }
+.skip-mark { color: #b26a00; font-weight: bold; }
+details { border: 1px solid #ddd; border-radius: 4px; margin: 6px 0; padding: 6px 10px; }
+summary { cursor: pointer; }
+pre { background: #f7f7f7; padding: 8px; overflow-x: auto; white-space: pre-wrap; word-break: break-all; font-size: 12px; }
+</style>
+</head>
+<body>
+<h1>车辆采集前检测报告</h1>
+{{if .Result.Success}}<div class="summary ok">车辆正常，可以正常采集驾驶信息。{{if .Result.SkippedCount}}另有 {{.Result.SkippedCount}} 项因车辆状态前置条件未满足而跳过，调整车辆状态后可复检。{{end}}</div>
+{{else if .Result.Cancelled}}<div class="summary fail">检测已取消，共 {{.Result.FailedCount}}/{{.Result.TotalCount}} 项未通过。</div>
+{{else}}<div class="summary fail">检测未通过：{{.Result.FailedCount}}/{{.Result.TotalCount}} 项失败。</div>{{end}}
+
+<table class="meta">
+<tr><th>检测时间</th><td>{{.Result.Timestamp}}</td></tr>
+<tr><th>车辆</th><td>{{.Meta.Vehicle}}</td></tr>
+<tr><th>操作员</th><td>{{.Meta.Operator}}</td></tr>
+<tr><th>检测项</th><td>{{if .Meta.Items}}{{.Meta.Items}}{{else}}全部{{end}}</td></tr>
+<tr><th>耗时</th><td>{{printf "%.1f" .Result.Duration}}s</td></tr>
+<tr><th>检测电脑</th><td>{{.Meta.Station}}</td></tr>
+{{if .Meta.HistoryID}}<tr><th>历史记录</th><td>{{.Meta.HistoryID}}</td></tr>{{end}}
+</table>
+
+<h2>检测结果</h2>
+<table>
+<tr><th>检测项</th><th>状态</th><th>提醒</th></tr>
+{{range .Rows}}<tr><td>{{.ID}}. {{.Item.Name}}</td><td>{{if .OK}}<span class="ok-mark">√</span>{{else if .Skipped}}<span class="skip-mark">跳过</span>{{else}}<span class="fail-mark">X</span>{{end}}</td><td>{{.Item.Message}}</td></tr>
+{{end}}</table>
+
+<h2>详细信息</h2>
+{{range .Rows}}<details{{if not .OK}} open{{end}}>
+<summary>{{if .OK}}<span class="ok-mark">√</span>{{else if .Skipped}}<span class="skip-mark">跳过</span>{{else}}<span class="fail-mark">X</span>{{end}} {{.ID}}. {{.Item.Name}}</summary>
+<table>
+<tr><th>分类</th><td>{{.Category}}</td></tr>
+{{if .Item.Host}}<tr><th>主机</th><td>{{.Item.Host}}</td></tr>{{end}}
+<tr><th>耗时</th><td>{{printf "%.2f" .Item.Duration}}s</td></tr>
+<tr><th>结论</th><td>{{.Item.Message}}</td></tr>
+{{if .Item.Target}}<tr><th>挂载目标</th><td>{{.Item.Target}}（可用 {{printf "%.0f" .Item.AvailGB}}G）</td></tr>{{end}}
+{{if .Item.Windows}}<tr><th>windows</th><td>{{.Item.Windows}}</td></tr>{{end}}
+{{with .Item.Content}}<tr><th>内容抽检</th><td><div><code>{{.Cmd}}</code> 采到 {{.Messages}} 条{{if .Points}}，点数 {{.Points}}{{end}}{{if .FrameIDs}}，frame_id {{.FrameIDs}}{{end}}</div>{{range .Problems}}<div class="fail-mark">{{.}}</div>{{end}}</td></tr>{{end}}
+{{with .Item.Latency}}<tr><th>时延</th><td>p50 {{printf "%.3f" .P50}}s，p95 {{printf "%.3f" .P95}}s，最大 {{printf "%.3f" .Max}}s（{{len .Samples}} 条）{{if .Problem}}<div class="fail-mark">{{.Problem}}</div>{{end}}</td></tr>{{end}}
+{{with .Item.Hz}}{{if .Samples}}<tr><th>hz</th><td>{{range .Samples}}<div>{{.Topic}} rate={{printf "%.2f" .Rate}}Hz min={{printf "%.3f" .MinDelta}}s max={{printf "%.3f" .MaxDelta}}s std_dev={{printf "%.4f" .StdDev}}s window={{.Window}}</div>{{end}}</td></tr>{{end}}{{end}}
+{{if .Item.Remediation}}<tr><th>自动修复</th><td>{{range .Item.Remediation}}<div>{{.}}</div>{{end}}</td></tr>{{end}}
+</table>
+{{if .Item.Output}}<pre>{{.Item.Output}}</pre>{{end}}
+{{range .Item.Evidence}}<details>
+<summary>[{{.Host}}] 第 {{.Attempt}} 次 <code>{{.Command}}</code> 退出码 {{.ExitCode}}</summary>
+<div>{{.Start}} → {{.End}}{{if .Error}}，错误：{{.Error}}{{end}}</div>
+{{if .Stdout}}<pre>{{.Stdout}}</pre>{{end}}{{if .Stderr}}<pre>{{.Stderr}}</pre>{{end}}
+</details>
+{{end}}</details>
+{{end}}
+</body>
+</html>
+`))
+
+// renderHTMLReport 输出单文件离线 HTML 报告（样式内联，不依赖外部资源），可直接附到采集工单
+func renderHTMLReport(w io.Writer, meta reportMeta, result CheckResult) error {
+	if meta.Station == "" {
+		meta.Station, _ = os.Hostname()
+	}
+	ids, items, ok := resultItems(result)
+	rows := make([]reportRow, 0, len(ids))
+	for _, id := range ids {
+		rows = append(rows, reportRow{ID: id, Category: itemCategory(id), OK: ok[id], Skipped: !ok[id] && itemSkipped(items[id]), Item: items[id]})
+	}
+
+	return REPORT_TEMPLATE.Execute(w, map[string]interface{}{
+		"Meta":   meta,
+		"Result": result,
+		"Rows":   rows,
+	})
+}
+
+func writeHTMLReport(path string, meta reportMeta, result CheckResult) error {
+	var buf bytes.Buffer
+	if err := renderHTMLReport(&buf, meta, result); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// ---------- Prometheus 指标 ----------
+
+// metricSet 按 Prometheus 文本格式（exposition format 0.0.4）累积指标，
+// 同名指标的样本集中输出在一组 HELP/TYPE 之后
+type metricSet struct {
+	names   []string
+	help    map[string]string
+	samples map[string][]string
+}
+
+func newMetricSet() *metricSet {
+	return &metricSet{help: make(map[string]string), samples: make(map[string][]string)}
+}
+
+// add 追加一个 gauge 样本，labels 为 name, value 交替排列
+func (m *metricSet) add(name, help string, value float64, labels ...string) {
+	if _, ok := m.help[name]; !ok {
+		m.names = append(m.names, name)
+		m.help[name] = help
+	}
+	var b strings.Builder
+	b.WriteString(name)
+	if len(labels) > 0 {
+		b.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			fmt.Fprintf(&b, "%s=\"%s\"", labels[i], escapeLabel(labels[i+1]))
+		}
+		b.WriteByte('}')
+	}
+	b.WriteByte(' ')
+	b.WriteString(strconv.FormatFloat(value, 'f', -1, 64))
+	m.samples[name] = append(m.samples[name], b.String())
+}
+
+func (m *metricSet) String() string {
+	var b strings.Builder
+	for _, name := range m.names {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s gauge\n", name, m.help[name], name)
+		for _, line := range m.samples[name] {
+			b.WriteString(line)
+			b.WriteByte('\n')
+		}
+	}
+	return b.String()
+}
+
+func escapeLabel(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return strings.ReplaceAll(s, "\n", `\n`)
+}
+
+func boolGauge(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// addResultMetrics 把一辆车的检测结果写入指标
+func addResultMetrics(m *metricSet, vehicle string, result CheckResult) {
+	m.add("check_car_run_success", "最近一次检测是否全部通过", boolGauge(result.Success), "vehicle", vehicle)
+	m.add("check_car_run_duration_seconds", "最近一次检测总耗时", result.Duration, "vehicle", vehicle)
+	m.add("check_car_run_failed_items", "最近一次检测失败项数量", float64(result.FailedCount), "vehicle", vehicle)
+	m.add("check_car_run_skipped_items", "最近一次检测因车辆状态前置条件未满足而跳过的项数量", float64(result.SkippedCount), "vehicle", vehicle)
+	if t, err := time.Parse(time.RFC3339, result.Timestamp); err == nil {
+		m.add("check_car_run_timestamp_seconds", "最近一次检测完成时间（Unix 秒）", float64(t.Unix()), "vehicle", vehicle)
+	}
+
+	hosts := make([]string, 0, len(result.SSHConnect))
+	for h := range result.SSHConnect {
+		hosts = append(hosts, h)
+	}
+	sort.Strings(hosts)
+	for _, h := range hosts {
+		m.add("check_car_ssh_connect_seconds", "SSH 连接建立耗时", result.SSHConnect[h], "vehicle", vehicle, "host", h)
+	}
+
+	ids, items, ok := resultItems(result)
+	for _, id := range ids {
+		it := items[id]
+		labels := []string{"vehicle", vehicle, "host", it.Host, "id", strconv.Itoa(id), "name", it.Name, "category", itemCategory(id)}
+		// 跳过的项无法判定通过与否，不输出 check_pass，避免看板按失败告警
+		skipped := !ok[id] && itemSkipped(it)
+		if !skipped {
+			m.add("check_car_check_pass", "检测项是否通过", boolGauge(ok[id]), labels...)
+		}
+		m.add("check_car_check_skipped", "检测项是否因车辆状态前置条件未满足而跳过", boolGauge(skipped), labels...)
+		m.add("check_car_check_duration_seconds", "检测项耗时", it.Duration, labels...)
+
+		if it.Target != "" {
+			m.add("check_car_nas_avail_bytes", "NAS 挂载目标可用容量", it.AvailGB*1024*1024*1024,
+				"vehicle", vehicle, "host", it.Host, "target", it.Target)
+		}
+		if itemCategory(id) == "topic" {
+			topic := []string{"vehicle", vehicle, "host", it.Host, "name", it.Name}
+			m.add("check_car_topic_windows", "Topic 各采样 windows 的平均值", meanInts(it.Windows), topic...)
+			m.add("check_car_topic_window_samples", "Topic 解析到的 windows 采样数", float64(len(it.Windows)), topic...)
+			if it.Hz != nil && it.Hz.hasTiming() {
+				m.add("check_car_topic_rate_hz", "Topic 各采样平均频率的均值", it.Hz.MeanRate, topic...)
+				m.add("check_car_topic_max_delta_seconds", "Topic 消息最大间隔", it.Hz.MaxDelta, topic...)
+				m.add("check_car_topic_std_dev_seconds", "Topic 消息间隔标准差（各采样中的最大值）", it.Hz.MaxStdDev, topic...)
+			}
+			if it.Latency != nil && len(it.Latency.Samples) > 0 {
+				m.add("check_car_topic_latency_seconds", "Topic 消息到达 MDC 时相对 header 时间戳的时延", it.Latency.P50, append(topic, "quantile", "0.5")...)
+				m.add("check_car_topic_latency_seconds", "Topic 消息到达 MDC 时相对 header 时间戳的时延", it.Latency.P95, append(topic, "quantile", "0.95")...)
+			}
+		}
+	}
+}
+
+// writeMetricsFile 以 node_exporter textfile collector 的方式写指标：先写临时文件再改名，避免被读到半个文件
+func writeMetricsFile(path string, results []VehicleResult) error {
+	m := newMetricSet()
+	for _, vr := range results {
+		addResultMetrics(m, vr.VehicleID, vr.Result)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(m.String()), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// ---------- 录制与回放 (-record / -replay) ----------
+
+// exchange 一次与主机的交互（建立连接或执行命令）。命令中的密码已脱敏，回放时按主机、检测项和脱敏后的命令匹配
+type exchange struct {
+	Seq      int    `json:"seq"`
+	Host     string `json:"host"`
+	Scope    string `json:"scope,omitempty"` // 发起交互的检测项，见 evidenceLog.scope
+	Kind     string `json:"kind"`            // dial / exec
+	Command  string `json:"command,omitempty"`
+	ExitCode int    `json:"exit_code"`
+	Stdout   string `json:"stdout,omitempty"`
+	Stderr   string `json:"stderr,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// recordingMeta 录制文件中的检测信息，回放时默认沿用其中的检测项
+type recordingMeta struct {
+	Time     string `json:"time"`
+	Vehicle  string `json:"vehicle,omitempty"`
+	Items    string `json:"items"`
+	Jump     string `json:"jump,omitempty"`
+	Exchange int    `json:"exchange_count"`
+}
+
+// recordingExecutor 通过 inner 执行，同时记录每次交互
+type recordingExecutor struct {
+	inner     Executor
+	mu        sync.Mutex
+	exchanges []exchange
+}
+
+func (r *recordingExecutor) add(e exchange) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e.Seq = len(r.exchanges) + 1
+	r.exchanges = append(r.exchanges, e)
+}
+
+func (r *recordingExecutor) Dial(host, scope string) (Conn, error) {
+	c, err := r.inner.Dial(host, scope)
+	e := exchange{Host: host, Scope: scope, Kind: "dial"}
+	if err != nil {
+		e.ExitCode = -1
+		e.Error = redactSecrets(err.Error())
+		r.add(e)
+		return nil, err
+	}
+	r.add(e)
+	return &recordingConn{Conn: c, host: host, scope: scope, rec: r}, nil
+}
+
+type recordingConn struct {
+	Conn
+	host  string
+	scope string
+	rec   *recordingExecutor
+}
+
+func (c *recordingConn) Exec(cmd string, timeout time.Duration) (int, string, string, error) {
+	code, stdout, stderr, err := c.Conn.Exec(cmd, timeout)
+	e := exchange{Host: c.host, Scope: c.scope, Kind: "exec", Command: redactSecrets(cmd), ExitCode: code,
+		Stdout: redactSecrets(stdout), Stderr: redactSecrets(stderr)}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	c.rec.add(e)
+	return code, stdout, stderr, err
+}
+
+// saveRecording 把录制的交互和本次结果写入 tar：meta.json、result.json、exchanges/NNNNNN.json
+func saveRecording(path string, meta recordingMeta, exchanges []exchange, result CheckResult) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	tw := tar.NewWriter(f)
+	now := time.Now()
+	writeEntry := func(name string, v interface{}) error {
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return err
+		}
+		hdr := &tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), ModTime: now}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		_, err = tw.Write(data)
+		return err
+	}
+
+	meta.Exchange = len(exchanges)
+	if err := writeEntry("meta.json", meta); err != nil {
+		return err
+	}
+	if err := writeEntry("result.json", result); err != nil {
+		return err
+	}
+	for _, e := range exchanges {
+		if err := writeEntry(fmt.Sprintf("exchanges/%06d.json", e.Seq), e); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+// replayExecutor 按录制文件回答连接和命令，不访问网络。
+// 交互按主机、检测项和命令分队列，同一队列按录制顺序依次返回（如 pmupload 的两次尝试）；
+// 每个检测项内部是顺序执行的，因此各项拿到的连接失败、命令输出与录制时相同，与并发顺序无关。
+// 录制中没有或已用完的交互记入 problems，回放结果视为与录制不一致。
+type replayExecutor struct {
+	mu       sync.Mutex
+	queues   map[string][]exchange
+	used     map[string]int
+	problems []string
+	meta     recordingMeta
+	result   *CheckResult // 录制时的结果，用于比对
+}
+
+func replayKey(host, scope, kind, cmd string) string {
+	return host + "\x00" + scope + "\x00" + kind + "\x00" + cmd
+}
+
+func loadRecording(path string) (*replayExecutor, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := &replayExecutor{queues: make(map[string][]exchange), used: make(map[string]int)}
+	var exchanges []exchange
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("读取录制文件失败: %v", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case hdr.Name == "meta.json":
+			err = json.Unmarshal(data, &r.meta)
+		case hdr.Name == "result.json":
+			r.result = &CheckResult{}
+			err = json.Unmarshal(data, r.result)
+		case strings.HasPrefix(hdr.Name, "exchanges/"):
+			var e exchange
+			err = json.Unmarshal(data, &e)
+			exchanges = append(exchanges, e)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("解析 %s 失败: %v", hdr.Name, err)
+		}
+	}
+
+	sort.Slice(exchanges, func(i, j int) bool { return exchanges[i].Seq < exchanges[j].Seq })
+	for _, e := range exchanges {
+		key := replayKey(e.Host, e.Scope, e.Kind, e.Command)
+		r.queues[key] = append(r.queues[key], e)
+	}
+	return r, nil
+}
+
+// next 取出下一条匹配的交互；录制中没有或已用完时记入 problems 并返回错误
+func (r *replayExecutor) next(host, scope, kind, cmd string) (exchange, error) {
+	key := replayKey(host, scope, kind, cmd)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	what := fmt.Sprintf("[%s] %s", scope, host)
+	if kind == "exec" {
+		what += " $ " + cmd
+	} else {
+		what = "连接 " + what
+	}
+	q := r.queues[key]
+	i := r.used[key]
+	r.used[key] = i + 1
+	var err error
+	switch {
+	case len(q) == 0:
+		err = fmt.Errorf("录制中没有 %s 的记录", what)
+	case i >= len(q):
+		err = fmt.Errorf("%s 的记录已用完（录制 %d 次，回放第 %d 次）", what, len(q), i+1)
+	default:
+		return q[i], nil
+	}
+	r.problems = append(r.problems, err.Error())
+	return exchange{}, err
+}
+
+// mismatches 返回回放中遇到的录制缺失或已用完的交互
+func (r *replayExecutor) mismatches() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.problems...)
+}
+
+func (r *replayExecutor) Dial(host, scope string) (Conn, error) {
+	e, err := r.next(host, scope, "dial", "")
+	if err != nil {
+		return nil, err
+	}
+	if e.Error != "" {
+		return nil, errors.New(e.Error)
+	}
+	return &replayConn{host: host, scope: scope, rec: r}, nil
+}
+
+type replayConn struct {
+	host  string
+	scope string
+	rec   *replayExecutor
+}
+
+func (c *replayConn) Exec(cmd string, timeout time.Duration) (int, string, string, error) {
+	e, err := c.rec.next(c.host, c.scope, "exec", redactSecrets(cmd))
+	if err != nil {
+		return -1, "", "", err
+	}
+	if e.Error != "" {
+		err = errors.New(e.Error)
+	}
+	return e.ExitCode, e.Stdout, e.Stderr, err
+}
+
+func (c *replayConn) Close() error { return nil }
+
+// diffVerdicts 比较两次结果中每一项的通过与否和提示，返回不一致的项
+func diffVerdicts(want, got CheckResult) []string {
+	wantIDs, wantItems, wantOK := resultItems(want)
+	_, gotItems, gotOK := resultItems(got)
+
+	var diffs []string
+	seen := make(map[int]bool)
+	for _, id := range wantIDs {
+		seen[id] = true
+		g, ok := gotItems[id]
+		switch {
+		case !ok:
+			diffs = append(diffs, fmt.Sprintf("%d. %s: 回放中缺少该项", id, wantItems[id].Name))
+		case itemStatusText(gotOK[id], g) != itemStatusText(wantOK[id], wantItems[id]):
+			diffs = append(diffs, fmt.Sprintf("%d. %s: 录制时%s，回放%s", id, g.Name, itemStatusText(wantOK[id], wantItems[id]), itemStatusText(gotOK[id], g)))
+		case g.Message != wantItems[id].Message:
+			diffs = append(diffs, fmt.Sprintf("%d. %s: 提示不同\n    录制: %s\n    回放: %s", id, g.Name, wantItems[id].Message, g.Message))
+		}
+	}
+	for id, g := range gotItems {
+		if !seen[id] {
+			diffs = append(diffs, fmt.Sprintf("%d. %s: 录制中没有该项", id, g.Name))
+		}
+	}
+	sort.Strings(diffs)
+	return diffs
+}
+
+// ---------- 采集后数据校验 (verify) ----------
+
+// VerifyResult 采集后数据校验结果
+type VerifyResult struct {
+	Timestamp string       `json:"timestamp"`
+	Success   bool         `json:"success"`
+	Duration  float64      `json:"duration_seconds"`
+	Since     string       `json:"since"`
+	Until     string       `json:"until"`
+	Hosts     []HostVerify `json:"hosts"`
+}
+
+// HostVerify 单台 MDC 录制目录的校验结果
+type HostVerify struct {
+	Host       string        `json:"host"`
+	Name       string        `json:"name"`
+	OK         bool          `json:"ok"`
+	Error      string        `json:"error,omitempty"`
+	Dir        string        `json:"dir"`
+	FileCount  int           `json:"file_count"`
+	TotalBytes int64         `json:"total_bytes"`
+	Groups     []GroupVerify `json:"groups"`
+	Missing    []string      `json:"missing_groups"` // 配置中期望、但时间窗口内没有任何文件的分组
+	Problems   []string      `json:"problems"`
+}
+
+// GroupVerify 按 topic/传感器（文件所在目录）汇总的录制文件统计
+type GroupVerify struct {
+	Name           string   `json:"name"`
+	FileCount      int      `json:"file_count"`
+	TotalBytes     int64    `json:"total_bytes"`
+	FirstFileTime  string   `json:"first_file_time"`
+	LastFileTime   string   `json:"last_file_time"`
+	Gaps           []string `json:"gaps"`
+	ZeroByteFiles  []string `json:"zero_byte_files"`
+	TruncatedFiles []string `json:"truncated_files"`
+}
+
+type recordFile struct {
+	Path  string
+	Size  int64
+	MTime time.Time
+}
+
+// listRecordFiles 列出录制目录下修改时间落在 [since, until) 内的文件，Path 为相对录制目录的路径。
+// 只用 find -mmin 和 stat -c（busybox 也支持）：先按远端时钟粗筛最近的文件，再在本地按 [since, until) 精确过滤
+func listRecordFiles(client *sshConn, dir string, since, until time.Time) ([]recordFile, error) {
+	minutes := int((time.Since(since)+VERIFY_CLOCK_MARGIN)/time.Minute) + 1
+	cmd := fmt.Sprintf("find %s -type f -mmin -%d -exec stat -c '%%Y %%s %%n' {} +", dir, minutes)
+	code, out, errOut, err := execCmd(client, cmd, VERIFY_TIMEOUT)
+	if err != nil {
+		return nil, fmt.Errorf("列出录制文件超时")
+	}
+	if code != 0 {
+		return nil, fmt.Errorf("列出录制文件失败: %s", strings.TrimSpace(errOut))
+	}
+
+	root := strings.TrimRight(dir, "/") + "/"
+	var files []recordFile
+	for _, line := range strings.Split(out, "\n") {
+		parts := strings.SplitN(strings.TrimSpace(line), " ", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		sec, err1 := strconv.ParseInt(parts[0], 10, 64)
+		size, err2 := strconv.ParseInt(parts[1], 10, 64)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		mtime := time.Unix(sec, 0)
+		if mtime.Before(since) || !mtime.Before(until) {
+			continue
+		}
+		files = append(files, recordFile{
+			Path:  strings.TrimPrefix(parts[2], root),
+			Size:  size,
+			MTime: mtime,
+		})
+	}
+	return files, nil
+}
+
+// recordGroupName 以文件所在目录（相对录制目录的路径）作为 topic/传感器分组，录制目录根下的文件归入 "."
+func recordGroupName(path string) string {
+	if idx := strings.LastIndex(path, "/"); idx >= 0 {
+		return path[:idx]
+	}
+	return "."
+}
+
+// analyzeRecordGroup 统计一组录制文件：窗口开始到第一个文件、相邻文件之间、最后一个文件到窗口结束的间隔过大均视为断档；
+// 除最后一个（可能仍在写入）外，大小不足同组中位数 VERIFY_TRUNCATED_RATIO 的文件视为截断。
+func analyzeRecordGroup(name string, files []recordFile, since, until time.Time, maxGap time.Duration) GroupVerify {
+	sort.Slice(files, func(i, j int) bool { return files[i].MTime.Before(files[j].MTime) })
+
+	first, last := files[0].MTime, files[len(files)-1].MTime
+	g := GroupVerify{
+		Name:           name,
+		FileCount:      len(files),
+		FirstFileTime:  first.Format(time.RFC3339),
+		LastFileTime:   last.Format(time.RFC3339),
+		Gaps:           []string{},
+		ZeroByteFiles:  []string{},
+		TruncatedFiles: []string{},
+	}
+
+	if d := first.Sub(since); d > maxGap {
+		g.Gaps = append(g.Gaps, fmt.Sprintf("开始 %s ~ %s（%s）", since.Format("15:04:05"), first.Format("15:04:05"), d.Round(time.Second)))
+	}
+	sizes := make([]int64, 0, len(files))
+	for i, f := range files {
+		g.TotalBytes += f.Size
+		if f.Size > 0 {
+			sizes = append(sizes, f.Size)
+		} else {
+			g.ZeroByteFiles = append(g.ZeroByteFiles, f.Path)
+		}
+		if i > 0 {
+			if d := f.MTime.Sub(files[i-1].MTime); d > maxGap {
+				g.Gaps = append(g.Gaps, fmt.Sprintf("%s ~ %s（%s）",
+					files[i-1].MTime.Format("15:04:05"), f.MTime.Format("15:04:05"), d.Round(time.Second)))
+			}
+		}
+	}
+	if d := until.Sub(last); d > maxGap {
+		g.Gaps = append(g.Gaps, fmt.Sprintf("%s ~ 结束 %s（%s）", last.Format("15:04:05"), until.Format("15:04:05"), d.Round(time.Second)))
+	}
+
+	if len(sizes) >= 3 {
+		sort.Slice(sizes, func(i, j int) bool { return sizes[i] < sizes[j] })
+		median := sizes[len(sizes)/2]
+		for _, f := range files[:len(files)-1] {
+			if f.Size > 0 && float64(f.Size) < float64(median)*VERIFY_TRUNCATED_RATIO {
+				g.TruncatedFiles = append(g.TruncatedFiles, f.Path)
+			}
+		}
+	}
+	return g
+}
+
+// recordGroups 返回 verify 时期望出现的录制分组：配置了 record_groups 时取配置，否则取各 Topic 名（去掉开头的 /）
+func (m MDCConfig) recordGroups() []string {
+	if len(m.RecordGroups) > 0 {
+		return m.RecordGroups
+	}
+	var groups []string
+	seen := make(map[string]bool)
+	for _, t := range m.Topics {
+		name := strings.TrimPrefix(topicOfCmd(t.Cmd), "/")
+		if name != "" && !seen[name] {
+			seen[name] = true
+			groups = append(groups, name)
+		}
+	}
+	return groups
+}
+
+// missingGroups 返回没有对应分组的期望项；分组路径等于期望项或以 "/期望项" 结尾（如按日期分目录）即视为存在
+func missingGroups(expected []string, groups map[string][]recordFile) []string {
+	missing := []string{}
+	for _, e := range expected {
+		e = strings.Trim(e, "/")
+		found := false
+		for g := range groups {
+			if g == e || strings.HasSuffix(g, "/"+e) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, e)
+		}
+	}
+	return missing
+}
+
+func verifyHost(host, name, dir string, expected []string, since, until time.Time, maxGap time.Duration) HostVerify {
+	hv := HostVerify{Host: host, Name: name, Dir: dir, Groups: []GroupVerify{}, Missing: []string{}, Problems: []string{}}
+
+	client, err := dialHost(host, nil, 1)
+	if err != nil {
+		hv.Error = "SSH 连接失败，请上电或插上网线"
+		return hv
+	}
+	defer client.Close()
+
+	files, err := listRecordFiles(client, dir, since, until)
+	if err != nil {
+		hv.Error = err.Error()
+		return hv
+	}
+	if len(files) == 0 {
+		hv.Problems = append(hv.Problems, "时间窗口内没有录制文件")
+	}
+
+	grouped := make(map[string][]recordFile)
+	for _, f := range files {
+		key := recordGroupName(f.Path)
+		grouped[key] = append(grouped[key], f)
+	}
+	names := make([]string, 0, len(grouped))
+	for k := range grouped {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	for _, k := range names {
+		g := analyzeRecordGroup(k, grouped[k], since, until, maxGap)
+		hv.FileCount += g.FileCount
+		hv.TotalBytes += g.TotalBytes
+		if len(g.Gaps) > 0 {
+			hv.Problems = append(hv.Problems, fmt.Sprintf("%s 存在 %d 处断档", k, len(g.Gaps)))
+		}
+		if len(g.ZeroByteFiles) > 0 {
+			hv.Problems = append(hv.Problems, fmt.Sprintf("%s 有 %d 个空文件", k, len(g.ZeroByteFiles)))
+		}
+		if len(g.TruncatedFiles) > 0 {
+			hv.Problems = append(hv.Problems, fmt.Sprintf("%s 有 %d 个疑似截断文件", k, len(g.TruncatedFiles)))
+		}
+		hv.Groups = append(hv.Groups, g)
+	}
+
+	hv.Missing = missingGroups(expected, grouped)
+	if len(hv.Missing) > 0 {
+		hv.Problems = append(hv.Problems, fmt.Sprintf("%d 个 topic 没有录制文件: %s", len(hv.Missing), strings.Join(hv.Missing, ", ")))
+	}
+
+	hv.OK = len(hv.Problems) == 0
+	return hv
+}
+
+// runVerify 校验车辆两台 MDC 的录制数据；expect 为 true 时检查配置中的每个 topic 都有录制文件
+func runVerify(v VehicleConfig, dir string, since, until time.Time, maxGap time.Duration, expect bool) VerifyResult {
+	startTime := time.Now()
+	mdcs := []MDCConfig{v.MDC1, v.MDC2}
+
+	hosts := make([]HostVerify, len(mdcs))
+	var wg sync.WaitGroup
+	for i, m := range mdcs {
+		var expected []string
+		if expect {
+			expected = m.recordGroups()
+		}
+		wg.Add(1)
+		go func(i int, host, name string, expected []string) {
+			defer wg.Done()
+			hosts[i] = verifyHost(host, name, dir, expected, since, until, maxGap)
+		}(i, m.Host, m.Name, expected)
+	}
+	wg.Wait()
+
+	success := true
+	for _, h := range hosts {
+		if !h.OK {
+			success = false
+		}
+	}
+
+	return VerifyResult{
+		Timestamp: time.Now().Format(time.RFC3339),
+		Success:   success,
+		Duration:  time.Since(startTime).Seconds(),
+		Since:     since.Format(time.RFC3339),
+		Until:     until.Format(time.RFC3339),
+		Hosts:     hosts,
+	}
+}
+
+// parseTimeFlag 支持 RFC3339 或本地时间 "2006-01-02 15:04:05" / "2006-01-02 15:04"
+func parseTimeFlag(s string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02 15:04"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("无法解析时间: %s", s)
+}
+
+func verifyMain(args []string) int {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	sinceFlag := fs.String("since", "", "采集开始时间（RFC3339 或 \"2006-01-02 15:04:05\"），默认 until 前 2 小时")
+	untilFlag := fs.String("until", "", "采集结束时间，默认当前时间")
+	dirFlag := fs.String("dir", MOUNT_POINT, "MDC 上的录制目录")
+	gapFlag := fs.Duration("max-gap", VERIFY_MAX_GAP, "同一 topic 相邻文件之间、以及与时间窗口首尾允许的最大时间间隔")
+	expectFlag := fs.Bool("expect", true, "检查配置中的每个 topic（或 record_groups）在时间窗口内都有录制文件")
+	vehicle := fs.String("vehicle", os.Getenv("CHECK_CAR_VEHICLE"), "车辆编号，与 -inventory 一起使用")
+	inventoryPath := fs.String("inventory", "", "车队清单文件，指定后校验 -vehicle 对应车辆的 MDC")
+	addJumpFlag(fs)
+	fs.Parse(args)
+
+	v, err := selectVehicle(*inventoryPath, *vehicle)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	until := time.Now()
+	if *untilFlag != "" {
+		t, err := parseTimeFlag(*untilFlag)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		until = t
+	}
+	since := until.Add(-2 * time.Hour)
+	if *sinceFlag != "" {
+		t, err := parseTimeFlag(*sinceFlag)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		since = t
+	}
+
+	result := runVerify(v, *dirFlag, since, until, *gapFlag, *expectFlag)
+	jsonBytes, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "JSON序列化失败: %v\n", err)
+		return 1
+	}
+	fmt.Println(string(jsonBytes))
+
+	if result.Success {
+		return 0
+	}
+	return 1
+}
+
+// ---------- 中文宽度对齐（文本输出用） ----------
+func visualWidth(s string) int {
+	w := 0
+	for _, ch := range s {
+		if unicode.Is(unicode.Han, ch) || isFullWidth(ch) {
+			w += 2
+		} else {
+			w += 1
+		}
+	}
+	return w
+}
+
+func isFullWidth(r rune) bool {
+	// 简化判断: CJK 字符范围
+	return (r >= 0x1100 && r <= 0x115F) ||
+		(r >= 0x2E80 && r <= 0x9FFF) ||
+		(r >= 0xAC00 && r <= 0xD7AF) ||
+		(r >= 0xF900 && r <= 0xFAFF) ||
+		(r >= 0xFE10 && r <= 0xFE1F) ||
+		(r >= 0xFE30 && r <= 0xFE6F) ||
+		(r >= 0xFF00 && r <= 0xFF60) ||
+		(r >= 0xFFE0 && r <= 0xFFE6)
+}
+
+func padLeft(s string, width int) string {
+	w := visualWidth(s)
+	if w >= width {
+		return s
+	}
+	return s + strings.Repeat(" ", width-w)
+}
+
+// printTextTable 输出按中文宽度对齐的文本表格，首行为表头
+func printTextTable(rows [][]string) {
+	if len(rows) == 0 {
+		return
+	}
+	widths := make([]int, len(rows[0]))
+	for _, row := range rows {
+		for i, cell := range row {
+			if w := visualWidth(cell); i < len(widths) && w > widths[i] {
+				widths[i] = w
+			}
+		}
+	}
+	for n, row := range rows {
+		cells := make([]string, len(row))
+		for i, cell := range row {
+			cells[i] = padLeft(cell, widths[i])
+		}
+		fmt.Println(strings.TrimRight(strings.Join(cells, "  "), " "))
+		if n == 0 {
+			seps := make([]string, len(widths))
+			for i, w := range widths {
+				seps[i] = strings.Repeat("-", w)
+			}
+			fmt.Println(strings.Join(seps, "  "))
+		}
+	}
+}
+
+// ---------- 检测历史 (history) ----------
+
+// HistoryRecord 一次检测的历史记录，逐行追加写入 history.jsonl
+type HistoryRecord struct {
+	ID        string      `json:"id"`
+	VehicleID string      `json:"vehicle_id"`
+	Operator  string      `json:"operator"`
+	Items     string      `json:"items"`
+	Result    CheckResult `json:"result"`
+}
+
+// dataDir 返回本地数据目录：优先 CHECK_CAR_DATA_DIR，其次 $XDG_DATA_HOME/check_car，
+// 再次 ~/.local/share/check_car（Windows 为 %LOCALAPPDATA%\check_car）
+func dataDir() (string, error) {
+	if dir := os.Getenv("CHECK_CAR_DATA_DIR"); dir != "" {
+		return dir, nil
+	}
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return filepath.Join(dir, "check_car"), nil
+	}
+	if dir := os.Getenv("LOCALAPPDATA"); dir != "" {
+		return filepath.Join(dir, "check_car"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "share", "check_car"), nil
+}
+
+func historyFile() (string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "history.jsonl"), nil
+}
+
+// defaultOperator 未指定 -operator 时使用当前系统用户名
+func defaultOperator() string {
+	if u := os.Getenv("USER"); u != "" {
+		return u
+	}
+	return os.Getenv("USERNAME")
+}
+
+func appendHistory(rec HistoryRecord) error {
+	path, err := historyFile()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
+
+var (
+	historyMu     sync.Mutex
+	lastHistoryID string
+	historySeq    int
+)
+
+// newHistoryID 按时间生成记录 ID；同一毫秒内（如车队检测连续保存）追加序号避免重复
+func newHistoryID() string {
+	now := time.Now()
+	id := fmt.Sprintf("%s-%03d", now.Format("20060102-150405"), now.Nanosecond()/1e6)
+
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	if id == lastHistoryID {
+		historySeq++
+		return fmt.Sprintf("%s-%d", id, historySeq)
+	}
+	lastHistoryID, historySeq = id, 0
+	return id
+}
+
+// saveHistory 保存一次检测结果，失败只提示不影响检测本身
+func saveHistory(vehicleID, operator, items string, result CheckResult) string {
+	rec := HistoryRecord{
+		ID:        newHistoryID(),
+		VehicleID: vehicleID,
+		Operator:  operator,
+		Items:     items,
+		Result:    result,
+	}
+	if err := appendHistory(rec); err != nil {
+		fmt.Fprintf(os.Stderr, "保存检测历史失败: %v\n", err)
+		return ""
+	}
+	return rec.ID
+}
+
+// loadHistory 读取全部历史记录（按写入顺序），跳过无法解析的行
+func loadHistory() ([]HistoryRecord, error) {
+	path, err := historyFile()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []HistoryRecord
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var rec HistoryRecord
+		if json.Unmarshal(scanner.Bytes(), &rec) == nil && rec.ID != "" {
+			records = append(records, rec)
+		}
+	}
+	return records, scanner.Err()
+}
+
+// findHistory 按 ID 查找记录，也支持 last / last~N（倒数第 N+1 条）
+func findHistory(records []HistoryRecord, id string) (HistoryRecord, bool) {
+	if id == "last" || strings.HasPrefix(id, "last~") {
+		back := 0
+		if n, err := strconv.Atoi(strings.TrimPrefix(id, "last~")); err == nil {
+			back = n
+		}
+		if idx := len(records) - 1 - back; idx >= 0 && idx < len(records) {
+			return records[idx], true
+		}
+		return HistoryRecord{}, false
+	}
+	for _, rec := range records {
+		if rec.ID == id {
+			return rec, true
+		}
+	}
+	return HistoryRecord{}, false
+}
+
+// resultItems 把 passed/failed/skipped 合并为按检测项 ID 排序的列表；skipped 的项 ok 为 false，用 itemSkipped 区分
+func resultItems(res CheckResult) ([]int, map[int]ResultItem, map[int]bool) {
+	items := make(map[int]ResultItem)
+	ok := make(map[int]bool)
+	for k, v := range res.Passed {
+		if id, err := strconv.Atoi(k); err == nil {
+			items[id], ok[id] = v, true
+		}
+	}
+	for _, m := range []map[string]ResultItem{res.Failed, res.Skipped} {
+		for k, v := range m {
+			if id, err := strconv.Atoi(k); err == nil {
+				items[id], ok[id] = v, false
+			}
+		}
+	}
+	ids := make([]int, 0, len(items))
+	for id := range items {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids, items, ok
+}
+
+// itemSkipped 报告未通过的检测项是否因车辆状态前置条件未满足而跳过（不计为失败）
+func itemSkipped(it ResultItem) bool {
+	return it.Precondition != ""
+}
+
+// itemStatusText 返回检测项的状态：通过、失败或跳过
+func itemStatusText(ok bool, it ResultItem) string {
+	if !ok && itemSkipped(it) {
+		return "跳过"
+	}
+	return statusText(ok)
+}
+
+func statusText(ok bool) string {
+	if ok {
+		return "通过"
+	}
+	return "失败"
+}
+
+func printHistoryList(records []HistoryRecord, limit int, vehicle string) {
+	rows := [][]string{{"ID", "时间", "车辆", "操作员", "结果", "失败/总数"}}
+	for i := len(records) - 1; i >= 0 && len(rows) <= limit; i-- {
+		rec := records[i]
+		if vehicle != "" && rec.VehicleID != vehicle {
+			continue
+		}
+		rows = append(rows, []string{rec.ID, rec.Result.Timestamp, rec.VehicleID, rec.Operator,
+			statusText(rec.Result.Success), fmt.Sprintf("%d/%d", rec.Result.FailedCount, rec.Result.TotalCount)})
+	}
+	printTextTable(rows)
+}
+
+// printHistoryDiff 输出两次检测之间状态或提醒发生变化的检测项
+func printHistoryDiff(a, b HistoryRecord) {
+	fmt.Printf("A: %s  %s  车辆=%s  %s\n", a.ID, a.Result.Timestamp, a.VehicleID, statusText(a.Result.Success))
+	fmt.Printf("B: %s  %s  车辆=%s  %s\n\n", b.ID, b.Result.Timestamp, b.VehicleID, statusText(b.Result.Success))
+
+	idsA, itemsA, okA := resultItems(a.Result)
+	idsB, itemsB, okB := resultItems(b.Result)
+	seen := make(map[int]bool)
+	var ids []int
+	for _, id := range append(idsA, idsB...) {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+
+	unchanged := 0
+	for _, id := range ids {
+		ia, inA := itemsA[id]
+		ib, inB := itemsB[id]
+		switch {
+		case !inA:
+			fmt.Printf("[%d] %s: 仅 B 检测，%s  %s\n", id, ib.Name, itemStatusText(okB[id], ib), ib.Message)
+		case !inB:
+			fmt.Printf("[%d] %s: 仅 A 检测，%s  %s\n", id, ia.Name, itemStatusText(okA[id], ia), ia.Message)
+		case itemStatusText(okA[id], ia) != itemStatusText(okB[id], ib):
+			fmt.Printf("[%d] %s: %s -> %s\n    A: %s\n    B: %s\n", id, ia.Name,
+				itemStatusText(okA[id], ia), itemStatusText(okB[id], ib), ia.Message, ib.Message)
+		case ia.Message != ib.Message:
+			fmt.Printf("[%d] %s: 仍%s，提醒变化\n    A: %s\n    B: %s\n", id, ia.Name,
+				itemStatusText(okA[id], ia), ia.Message, ib.Message)
+		default:
+			unchanged++
+		}
+	}
+	fmt.Printf("\n无变化的检测项: %d\n", unchanged)
+}
+
+func historyMain(args []string) int {
+	usage := "用法: check_json history list [-n=20] [-vehicle=ID] | show <id> | diff <a> <b> | report <id> <file.html>（id 可用 last、last~1）"
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, usage)
+		return 2
+	}
+
+	records, err := loadHistory()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "读取检测历史失败: %v\n", err)
+		return 1
+	}
+
+	switch args[0] {
+	case "list":
+		fs := flag.NewFlagSet("history list", flag.ExitOnError)
+		limit := fs.Int("n", 20, "最多显示的条数")
+		vehicle := fs.String("vehicle", "", "只显示指定车辆")
+		fs.Parse(args[1:])
+		printHistoryList(records, *limit, *vehicle)
+		return 0
+	case "show":
+		if len(args) != 2 {
+			fmt.Fprintln(os.Stderr, usage)
+			return 2
+		}
+		rec, ok := findHistory(records, args[1])
+		if !ok {
+			fmt.Fprintf(os.Stderr, "检测记录不存在: %s\n", args[1])
+			return 1
+		}
+		jsonBytes, _ := json.MarshalIndent(rec, "", "  ")
+		fmt.Println(string(jsonBytes))
+		return 0
+	case "diff":
+		if len(args) != 3 {
+			fmt.Fprintln(os.Stderr, usage)
+			return 2
+		}
+		a, okA := findHistory(records, args[1])
+		b, okB := findHistory(records, args[2])
+		if !okA || !okB {
+			fmt.Fprintln(os.Stderr, "检测记录不存在")
+			return 1
+		}
+		printHistoryDiff(a, b)
+		return 0
+	case "report":
+		if len(args) != 3 {
+			fmt.Fprintln(os.Stderr, usage)
+			return 2
+		}
+		rec, ok := findHistory(records, args[1])
+		if !ok {
+			fmt.Fprintf(os.Stderr, "检测记录不存在: %s\n", args[1])
+			return 1
+		}
+		meta := reportMeta{Vehicle: rec.VehicleID, Operator: rec.Operator, Items: rec.Items, HistoryID: rec.ID}
+		if err := writeHTMLReport(args[2], meta, rec.Result); err != nil {
+			fmt.Fprintf(os.Stderr, "生成报告失败: %v\n", err)
+			return 1
+		}
+		fmt.Printf("已生成 %s\n", args[2])
+		return 0
+	}
+
+	fmt.Fprintln(os.Stderr, usage)
+	return 2
+}
+
+// ---------- 趋势分析 (report trends) ----------
+
+var WINDOWS_MSG_RE = regexp.MustCompile(`windows=\[([0-9 ]*)\]`)
+
+var SPARK_CHARS = []rune("▁▂▃▄▅▆▇█")
+
+type trendPoint struct {
+	Time    time.Time
+	Vehicle string
+	Value   float64
+}
+
+// trendSeries 一个 Topic 的平均 windows、平均频率或一个挂载目标的可用容量随时间的变化
+type trendSeries struct {
+	Kind   string // topic / rate / nas
+	Name   string
+	Points []trendPoint
+}
+
+// itemWindows 取 Topic 检测项的 windows；早期历史记录没有结构化字段时从提醒文案中解析。
+// 第二个返回值表示该项是否为 Topic 检测。
+func itemWindows(it ResultItem) ([]int, bool) {
+	if len(it.Windows) > 0 {
+		return it.Windows, true
+	}
+	m := WINDOWS_MSG_RE.FindStringSubmatch(it.Message)
+	if m == nil {
+		return nil, false
+	}
+	var windows []int
+	for _, f := range strings.Fields(m[1]) {
+		if v, err := strconv.Atoi(f); err == nil {
+			windows = append(windows, v)
+		}
+	}
+	return windows, true
+}
+
+func meanInts(arr []int) float64 {
+	if len(arr) == 0 {
+		return 0
+	}
+	sum := 0
+	for _, v := range arr {
+		sum += v
+	}
+	return float64(sum) / float64(len(arr))
+}
+
+// collectTrends 从检测历史中提取各 Topic 平均 windows 和各挂载目标可用容量的时间序列
+func collectTrends(records []HistoryRecord, vehicle string, since time.Time) []*trendSeries {
+	index := make(map[string]*trendSeries)
+	var order []*trendSeries
+	add := func(kind, name string, p trendPoint) {
+		key := kind + "|" + name
+		ts, ok := index[key]
+		if !ok {
+			ts = &trendSeries{Kind: kind, Name: name}
+			index[key] = ts
+			order = append(order, ts)
+		}
+		ts.Points = append(ts.Points, p)
+	}
+
+	for _, rec := range records {
+		if vehicle != "" && rec.VehicleID != vehicle {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, rec.Result.Timestamp)
+		if err != nil || t.Before(since) {
+			continue
+		}
+		ids, items, _ := resultItems(rec.Result)
+		for _, id := range ids {
+			it := items[id]
+			if windows, isTopic := itemWindows(it); isTopic {
+				add("topic", it.Name, trendPoint{t, rec.VehicleID, meanInts(windows)})
+			}
+			if it.Hz != nil && it.Hz.hasTiming() {
+				add("rate", it.Name, trendPoint{t, rec.VehicleID, it.Hz.MeanRate})
+			}
+			if it.Target != "" && it.AvailGB > 0 {
+				add("nas", it.Target, trendPoint{t, rec.VehicleID, it.AvailGB})
+			}
+		}
+	}
+
+	sort.SliceStable(order, func(i, j int) bool { return order[i].Kind > order[j].Kind })
+	return order
+}
+
+func sparkline(values []float64) string {
+	if len(values) == 0 {
+		return ""
+	}
+	lo, hi := values[0], values[0]
+	for _, v := range values {
+		lo = math.Min(lo, v)
+		hi = math.Max(hi, v)
+	}
+	var b strings.Builder
+	for _, v := range values {
+		idx := len(SPARK_CHARS) - 1
+		if hi > lo {
+			idx = int((v - lo) / (hi - lo) * float64(len(SPARK_CHARS)-1))
+		}
+		b.WriteRune(SPARK_CHARS[idx])
+	}
+	return b.String()
+}
+
+// dailyMeans 按本地日期求每天的平均值，按日期先后排列
+func dailyMeans(points []trendPoint) []float64 {
+	sums := make(map[string]float64)
+	counts := make(map[string]int)
+	var days []string
+	for _, p := range points {
+		day := p.Time.Local().Format("2006-01-02")
+		if counts[day] == 0 {
+			days = append(days, day)
+		}
+		sums[day] += p.Value
+		counts[day]++
+	}
+	sort.Strings(days)
+	means := make([]float64, len(days))
+	for i, d := range days {
+		means[i] = sums[d] / float64(counts[d])
+	}
+	return means
+}
+
+// detectDrift 判断 Topic 是否在多天内持续下降：至少 TREND_MIN_DAYS 天数据，
+// 日均值线性拟合斜率为负，且最近一天比第一天下降超过 TREND_DRIFT_RATIO
+func detectDrift(points []trendPoint) (bool, string) {
+	days := dailyMeans(points)
+	n := len(days)
+	if n < TREND_MIN_DAYS || days[0] <= 0 {
+		return false, ""
+	}
+
+	var sumX, sumY, sumXY, sumXX float64
+	for i, y := range days {
+		x := float64(i)
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+	}
+	slope := (float64(n)*sumXY - sumX*sumY) / (float64(n)*sumXX - sumX*sumX)
+
+	first, last := days[0], days[n-1]
+	if slope < 0 && last < first*(1-TREND_DRIFT_RATIO) {
+		return true, fmt.Sprintf("近 %d 天从 %.1f 降至 %.1f（-%.0f%%），请提前排查", n, first, last, (1-last/first)*100)
+	}
+	return false, ""
+}
+
+func writeTrendsCSV(path string, series []*trendSeries) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	w.Write([]string{"time", "vehicle", "kind", "name", "value"})
+	for _, ts := range series {
+		for _, p := range ts.Points {
+			w.Write([]string{p.Time.Format(time.RFC3339), p.Vehicle, ts.Kind, ts.Name, strconv.FormatFloat(p.Value, 'f', 2, 64)})
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func printTrends(series []*trendSeries) {
+	// pmupload 输出频率时按频率判断持续下降，windows 只在没有频率数据时使用
+	hasRate := make(map[string]bool)
+	for _, ts := range series {
+		if ts.Kind == "rate" {
+			hasRate[ts.Name] = true
+		}
+	}
+
+	rows := [][]string{{"类型", "名称", "次数", "最新", "最小", "最大", "趋势", "提醒"}}
+	for _, ts := range series {
+		values := make([]float64, len(ts.Points))
+		lo, hi := math.Inf(1), math.Inf(-1)
+		for i, p := range ts.Points {
+			values[i] = p.Value
+			lo = math.Min(lo, p.Value)
+			hi = math.Max(hi, p.Value)
+		}
+		if len(values) > TREND_SPARK_POINTS {
+			values = values[len(values)-TREND_SPARK_POINTS:]
+		}
+
+		kind, unit, tip := "Topic windows", "", ""
+		switch ts.Kind {
+		case "nas":
+			kind, unit = "NAS 可用", "G"
+		case "rate":
+			kind, unit = "Topic 频率", "Hz"
+		}
+		if ts.Kind == "rate" || ts.Kind == "topic" && !hasRate[ts.Name] {
+			if drift, msg := detectDrift(ts.Points); drift {
+				tip = "持续下降：" + msg
+			}
+		}
+		latest := ts.Points[len(ts.Points)-1].Value
+		rows = append(rows, []string{kind, ts.Name, strconv.Itoa(len(ts.Points)),
+			fmt.Sprintf("%.1f%s", latest, unit), fmt.Sprintf("%.1f%s", lo, unit), fmt.Sprintf("%.1f%s", hi, unit),
+			sparkline(values), tip})
+	}
+	printTextTable(rows)
+}
+
+func reportMain(args []string) int {
+	if len(args) == 0 || args[0] != "trends" {
+		fmt.Fprintln(os.Stderr, "用法: check_json report trends [-vehicle=ID] [-days=30] [-csv=FILE]")
+		return 2
+	}
+
+	fs := flag.NewFlagSet("report trends", flag.ExitOnError)
+	vehicle := fs.String("vehicle", "", "只统计指定车辆")
+	days := fs.Int("days", 30, "统计最近多少天")
+	csvPath := fs.String("csv", "", "同时把原始数据点写入 CSV 文件")
+	fs.Parse(args[1:])
+
+	records, err := loadHistory()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "读取检测历史失败: %v\n", err)
+		return 1
+	}
+	series := collectTrends(records, *vehicle, time.Now().AddDate(0, 0, -*days))
+	if len(series) == 0 {
+		fmt.Println("没有可用的检测历史。")
+		return 0
+	}
+
+	printTrends(series)
+	if *csvPath != "" {
+		if err := writeTrendsCSV(*csvPath, series); err != nil {
+			fmt.Fprintf(os.Stderr, "写入 CSV 失败: %v\n", err)
+			return 1
+		}
+		fmt.Printf("\n已写入 %s\n", *csvPath)
+	}
+	return 0
+}
+
+// ---------- 车队检测 (fleet) ----------
+
+// VehicleResult 一辆车的检测结果
+type VehicleResult struct {
+	VehicleID string      `json:"vehicle_id"`
+	HistoryID string      `json:"history_id,omitempty"`
+	Result    CheckResult `json:"result"`
+}
+
+// FleetResult 车队检测结果
+type FleetResult struct {
+	Success     bool            `json:"success"`
+	Vehicles    []VehicleResult `json:"vehicles"`
+	FailedCount int             `json:"failed_count"` // 未通过的车辆数
+	TotalCount  int             `json:"total_count"`
+	Duration    float64         `json:"duration_seconds"`
+}
+
+// runFleet 并发检测多辆车，同时检测的车辆数不超过 concurrency。
+// 每辆车内部仍按 runCheck 的方式并发检测 Topic。
+func runFleet(ctx context.Context, vehicles []VehicleConfig, itemsStr string, concurrency int) FleetResult {
+	startTime := time.Now()
+	results := make([]VehicleResult, len(vehicles))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	for i, v := range vehicles {
+		wg.Add(1)
+		go func(i int, v VehicleConfig) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			results[i] = VehicleResult{
+				VehicleID: v.ID,
+				Result:    runCheck(ctx, v, parseItems(v, itemsStr)),
+			}
+		}(i, v)
+	}
+	wg.Wait()
+
+	failed := 0
+	for _, r := range results {
+		if !r.Result.Success {
+			failed++
+		}
+	}
+	return FleetResult{
+		Success:     failed == 0,
+		Vehicles:    results,
+		FailedCount: failed,
+		TotalCount:  len(results),
+		Duration:    float64(int(time.Since(startTime).Seconds()*10)) / 10,
+	}
+}
+
+func printFleetResult(fr FleetResult) {
+	rows := [][]string{{"车辆", "结果", "失败/总数", "耗时(s)", "历史ID"}}
+	for _, vr := range fr.Vehicles {
+		rows = append(rows, []string{
+			vr.VehicleID,
+			statusText(vr.Result.Success),
+			fmt.Sprintf("%d/%d", vr.Result.FailedCount, vr.Result.TotalCount),
+			fmt.Sprintf("%.1f", vr.Result.Duration),
+			vr.HistoryID,
+		})
+	}
+	printTextTable(rows)
+
+	for _, vr := range fr.Vehicles {
+		ids, items, ok := resultItems(vr.Result)
+		for _, status := range []string{"失败", "跳过"} {
+			header := false
+			for _, id := range ids {
+				if ok[id] || itemStatusText(false, items[id]) != status {
+					continue
+				}
+				if !header {
+					fmt.Printf("\n[%s] %s项:\n", vr.VehicleID, status)
+					header = true
+				}
+				fmt.Printf("  %2d. %s\n      %s\n", id, items[id].Name, items[id].Message)
+			}
+		}
+	}
+	fmt.Printf("\n共 %d 辆车，%d 辆未通过，总耗时 %.1fs\n", fr.TotalCount, fr.FailedCount, fr.Duration)
+}
+
+func fleetMain(args []string) int {
+	if len(args) == 0 || args[0] != "check" {
+		fmt.Fprintln(os.Stderr, "用法: check_json fleet check -inventory=FILE [-vehicles=ID,ID] [-items=...] [-concurrency=N] [-json]")
+		return 2
+	}
+
+	fs := flag.NewFlagSet("fleet check", flag.ExitOnError)
+	inventoryPath := fs.String("inventory", "inventory.json", "车队清单文件")
+	vehiclesFlag := fs.String("vehicles", "", "只检测指定车辆（逗号分隔的车辆编号），默认全部")
+	items := fs.String("items", "", "每辆车要检测的项目，同主命令 -items")
+	concurrency := fs.Int("concurrency", 0, "同时检测的车辆数，默认取清单中的 max_concurrency")
+	jsonOut := fs.Bool("json", false, "输出 JSON（包含每辆车的完整结果）")
+	metricsFile := fs.String("metrics-file", "", "同时把各车结果写成 Prometheus 指标文件（node_exporter textfile collector）")
+	operator := fs.String("operator", defaultOperator(), "操作员，记录到检测历史")
+	noHistory := fs.Bool("no-history", false, "不保存本次检测历史")
+	addJumpFlag(fs)
+	addWorkersFlag(fs)
+	addBatchFlag(fs)
+	fs.Parse(args[1:])
+
+	inv, err := loadInventory(*inventoryPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "读取车队清单失败: %v\n", err)
+		return 1
+	}
+
+	vehicles := inv.Vehicles
+	if *vehiclesFlag != "" {
+		byID := make(map[string]VehicleConfig)
+		for _, v := range inv.Vehicles {
+			byID[v.ID] = v
+		}
+		vehicles = nil
+		for _, id := range strings.Split(*vehiclesFlag, ",") {
+			id = strings.TrimSpace(id)
+			v, ok := byID[id]
+			if !ok {
+				fmt.Fprintf(os.Stderr, "清单中没有车辆 %s\n", id)
+				return 2
+			}
+			vehicles = append(vehicles, v)
+		}
+	}
+	if len(vehicles) == 0 {
+		fmt.Fprintln(os.Stderr, "清单中没有车辆")
+		return 2
+	}
+
+	limit := inv.MaxConcurrency
+	if *concurrency > 0 {
+		limit = *concurrency
+	}
+
+	fr := runFleet(context.Background(), vehicles, *items, limit)
+	if !*noHistory {
+		for i := range fr.Vehicles {
+			vr := &fr.Vehicles[i]
+			vr.HistoryID = saveHistory(vr.VehicleID, *operator, *items, vr.Result)
+		}
+	}
+	if *metricsFile != "" {
+		if err := writeMetricsFile(*metricsFile, fr.Vehicles); err != nil {
+			fmt.Fprintf(os.Stderr, "写入指标文件失败: %v\n", err)
+		}
+	}
+
+	if *jsonOut {
+		output, _ := json.MarshalIndent(fr, "", "  ")
+		fmt.Println(string(output))
+	} else {
+		printFleetResult(fr)
+	}
+
+	if fr.Success {
+		return 0
+	}
+	return 1
+}
+
+// ---------- Topic 目录比对 / 发现 (discover) ----------
+
+// PMUPLOAD_LIST_LINE_RE 匹配 pmupload adstopic list 输出中以 topic 名开头的行
+var PMUPLOAD_LIST_LINE_RE = regexp.MustCompile(`^\s*(/[A-Za-z0-9_./\-]+)`)
+
+// topicOfCmd 取出 pmupload 命令中的 topic 名（最后一个以 / 开头的参数）
+func topicOfCmd(cmd string) string {
+	fields := strings.Fields(cmd)
+	for i := len(fields) - 1; i >= 0; i-- {
+		if strings.HasPrefix(fields[i], "/") {
+			return fields[i]
+		}
+	}
+	return ""
+}
+
+// parseTopicList 解析 pmupload adstopic list 的输出，返回去重排序后的 topic 名
+func parseTopicList(text string) []string {
+	seen := make(map[string]bool)
+	var topics []string
+	for _, line := range strings.Split(text, "\n") {
+		m := PMUPLOAD_LIST_LINE_RE.FindStringSubmatch(line)
+		if m == nil || seen[m[1]] {
+			continue
+		}
+		seen[m[1]] = true
+		topics = append(topics, m[1])
+	}
+	sort.Strings(topics)
+	return topics
+}
+
+// listTopics 列出主机上当前发布的全部 topic，同时返回原始输出
+func listTopics(host string, log *evidenceLog) ([]string, string, error) {
+	client, err := dialHost(host, log, 1)
+	if err != nil {
+		return nil, "", fmt.Errorf("SSH 连接失败: %v", err)
+	}
+	defer client.Close()
+
+	_, out, errOut, err := execCmd(client, PMUPLOAD_LIST_CMD, PMUPLOAD_TIMEOUT)
+	merged := out
+	if out != "" && errOut != "" {
+		merged += "\n"
+	}
+	merged += errOut
+	if err != nil {
+		return nil, merged, err
+	}
+	return parseTopicList(merged), merged, nil
+}
+
+// ignoredTopic 判断 topic 是否匹配 ignore_topics 中的通配符
+func ignoredTopic(mdc MDCConfig, topic string) bool {
+	for _, pattern := range mdc.IgnoreTopics {
+		if ok, _ := path.Match(pattern, topic); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// diffTopicCatalog 对比已登记的 topic 和实际发布的 topic，返回未发布的和未登记的
+func diffTopicCatalog(mdc MDCConfig, published []string) (missing, extra []string) {
+	pub := make(map[string]bool)
+	for _, t := range published {
+		pub[t] = true
+	}
+	known := make(map[string]bool)
+	for _, tc := range mdc.Topics {
+		t := topicOfCmd(tc.Cmd)
+		known[t] = true
+		if t != "" && !pub[t] {
+			missing = append(missing, t)
+		}
+	}
+	for _, t := range published {
+		if !known[t] && !ignoredTopic(mdc, t) {
+			extra = append(extra, t)
+		}
+	}
+	return missing, extra
+}
+
+// checkTopicCatalog 检测 MDC 上已登记的 topic 是否都在发布列表中。
+// 有未发布的 topic 时判定失败；只有未登记的新 topic 时通过，但在提示中列出，便于发现新增或改名的传感器。
+func checkTopicCatalog(ctx context.Context, id int, mdc MDCConfig) internalResult {
+	name := fmt.Sprintf("%s Topic 目录", mdc.Name)
+	emitCheckStarted(ctx, id, name)
+	log := &evidenceLog{scope: strconv.Itoa(id)}
+	published, out, err := listTopics(mdc.Host, log)
+	r := internalResult{ID: id, Name: name, Host: mdc.Host, Output: redactSecrets(out), Evidence: log.list()}
+
+	switch {
+	case err != nil:
+		r.Message = fmt.Sprintf("无法获取 Topic 列表: %v", err)
+	case len(published) == 0:
+		r.Message = fmt.Sprintf("%s 没有输出任何 Topic，可能 pmupload 未启动或跑错IP", PMUPLOAD_LIST_CMD)
+	default:
+		missing, extra := diffTopicCatalog(mdc, published)
+		var parts []string
+		if len(missing) > 0 {
+			parts = append(parts, "未发布: "+strings.Join(missing, ", "))
+		}
+		if len(extra) > 0 {
+			parts = append(parts, "未登记: "+strings.Join(extra, ", "))
+		}
+		if len(missing) > 0 && len(extra) > 0 {
+			parts = append(parts, "可能已改名，可用 discover 生成新配置")
+		}
+		r.OK = len(missing) == 0
+		r.Message = fmt.Sprintf("发布 %d 个，已登记 %d 个", len(published), len(mdc.Topics))
+		if len(parts) > 0 {
+			r.Message += " | " + strings.Join(parts, " | ")
+		}
+	}
+	return finishCheck(ctx, r)
+}
+
+// discoveredMDC discover 输出的一台 MDC 的配置片段
+type discoveredMDC struct {
+	Name   string     `json:"name"`
+	Host   string     `json:"host"`
+	Topics []TopicCmd `json:"topics"`
+}
+
+// discoverTopics 按发布列表生成 topic 配置：已登记的沿用原名称和命令，新 topic 使用默认 hz 命令
+func discoverTopics(mdc MDCConfig, published []string) []TopicCmd {
+	known := make(map[string]TopicCmd)
+	for _, tc := range mdc.Topics {
+		known[topicOfCmd(tc.Cmd)] = tc
+	}
+	topics := []TopicCmd{}
+	for _, t := range published {
+		if ignoredTopic(mdc, t) {
+			continue
+		}
+		if tc, ok := known[t]; ok {
+			topics = append(topics, tc)
+			continue
+		}
+		topics = append(topics, TopicCmd{Name: mdc.Name + " " + t, Cmd: PMUPLOAD_HZ_CMD + " " + t})
+	}
+	return topics
+}
+
+func discoverMain(args []string) int {
+	fs := flag.NewFlagSet("discover", flag.ExitOnError)
+	inventoryPath := fs.String("inventory", "", "车队清单文件，指定后按 -vehicle 对应车辆的 MDC 配置比对")
+	vehicle := fs.String("vehicle", os.Getenv("CHECK_CAR_VEHICLE"), "车辆编号（配合 -inventory）")
+	addJumpFlag(fs)
+	fs.Parse(args)
+
+	v, err := selectVehicle(*inventoryPath, *vehicle)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	mdcs := []MDCConfig{v.MDC1, v.MDC2}
+	snippet := make(map[string]discoveredMDC)
+	exit := 0
+	for i, mdc := range mdcs {
+		key := fmt.Sprintf("mdc%d", i+1)
+		published, _, err := listTopics(mdc.Host, nil)
+		if err == nil && len(published) == 0 {
+			err = fmt.Errorf("%s 没有输出任何 Topic", PMUPLOAD_LIST_CMD)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s (%s): 无法获取 Topic 列表: %v\n", mdc.Name, mdc.Host, err)
+			exit = 1
+			continue
+		}
+		missing, extra := diffTopicCatalog(mdc, published)
+		fmt.Fprintf(os.Stderr, "%s (%s): 发布 %d 个，已登记 %d 个，未发布 %d 个，未登记 %d 个\n",
+			mdc.Name, mdc.Host, len(published), len(mdc.Topics), len(missing), len(extra))
+		for _, t := range missing {
+			fmt.Fprintf(os.Stderr, "  - %s\n", t)
+		}
+		for _, t := range extra {
+			fmt.Fprintf(os.Stderr, "  + %s\n", t)
+		}
+		snippet[key] = discoveredMDC{Name: mdc.Name, Host: mdc.Host, Topics: discoverTopics(mdc, published)}
+	}
+
+	// 输出可直接替换清单中 mdc1/mdc2 的 name、host、topics 的片段
+	output, _ := json.MarshalIndent(snippet, "", "  ")
+	fmt.Println(string(output))
+	return exit
+}
+
+// ---------- 故障信息收集 (collect) ----------
+
+// CollectCommand 收集时在每台主机上执行的命令，输出保存为 <host>/cmd/<name>.txt
+type CollectCommand struct {
+	Name string `json:"name"`
+	Cmd  string `json:"cmd"`
+}
+
+// CollectSpec 要收集的命令输出和文件。Files 支持 shell 通配符，单个文件只保留末尾 MaxFileBytes 字节
+type CollectSpec struct {
+	Commands     []CollectCommand `json:"commands"`
+	Files        []string         `json:"files"`
+	MaxFileBytes int64            `json:"max_file_bytes,omitempty"`
+}
+
+var DEFAULT_COLLECT_SPEC = CollectSpec{
+	Commands: []CollectCommand{
+		{"dmesg", "dmesg -T 2>/dev/null || dmesg"},
+		{"mount", "mount"},
+		{"df", "df -h"},
+		{"pmupload_topics", "timeout 8s pmupload adstopic list"},
+		{"ps", "ps aux 2>/dev/null || ps"},
+		{"network", "ip addr; ip route; ip -s link 2>/dev/null || ifconfig -a"},
+		{"uptime", "uptime; date"},
+	},
+	Files: []string{
+		"/var/log/messages",
+		"/var/log/syslog",
+		"/var/log/pmupload/*.log",
+		"/var/log/recorder/*.log",
+	},
+	MaxFileBytes: COLLECT_MAX_FILE_BYTES,
+}
+
+// CollectEntry 收集包中的一项（命令输出或文件）
+type CollectEntry struct {
+	Kind     string `json:"kind"` // cmd / file
+	Name     string `json:"name"` // 命令名或远程文件路径
+	Path     string `json:"path,omitempty"`
+	ExitCode int    `json:"exit_code"`
+	Bytes    int    `json:"bytes"`
+	Error    string `json:"error,omitempty"`
+}
+
+// CollectHost 一台主机的收集情况
+type CollectHost struct {
+	Host    string         `json:"host"`
+	Error   string         `json:"error,omitempty"`
+	Entries []CollectEntry `json:"entries"`
+
+	files map[string][]byte // 包内路径 -> 内容
+}
+
+// CollectManifest 收集包中的 manifest.json
+type CollectManifest struct {
+	Timestamp     string        `json:"timestamp"`
+	Vehicle       string        `json:"vehicle,omitempty"`
+	Operator      string        `json:"operator,omitempty"`
+	LastHistoryID string        `json:"last_history_id,omitempty"`
+	Duration      float64       `json:"duration_seconds"`
+	Hosts         []CollectHost `json:"hosts"`
+}
+
+func loadCollectSpec(path string) (CollectSpec, error) {
+	var spec CollectSpec
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return spec, err
+	}
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return spec, fmt.Errorf("解析 %s 失败: %v", path, err)
+	}
+	for _, c := range spec.Commands {
+		if c.Name == "" || c.Cmd == "" {
+			return spec, fmt.Errorf("%s: commands 中每项都需要 name 和 cmd", path)
+		}
+	}
+	if spec.MaxFileBytes <= 0 {
+		spec.MaxFileBytes = COLLECT_MAX_FILE_BYTES
+	}
+	return spec, nil
+}
+
+// shellQuote 用单引号包裹参数，供拼接远程命令
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// collectFileName 把命令名转换为包内文件名，如 ip/route -> ip_route
+func collectFileName(name string) string {
+	return strings.ReplaceAll(strings.TrimPrefix(name, "/"), "/", "_")
+}
+
+// collectFilePath 把远程文件路径转换为包内相对路径并保留目录结构，如 /var/log/a/b -> var/log/a/b；
+// 去掉 .. 等成分，避免解包时写到收集包目录之外
+func collectFilePath(remote string) string {
+	return strings.TrimPrefix(path.Clean("/"+remote), "/")
+}
+
+// collectHost 通过一条 SSH 连接依次执行命令、读取文件；单项失败不影响其余项
+func collectHost(host string, spec CollectSpec) CollectHost {
+	ch := CollectHost{Host: host, Entries: []CollectEntry{}, files: make(map[string][]byte)}
+
+	client, err := dialHost(host, nil, 1)
+	if err != nil {
+		ch.Error = "SSH 连接失败，请上电或插上网线"
+		return ch
+	}
+	defer client.Close()
+
+	for _, c := range spec.Commands {
+		code, stdout, stderr, err := execCmd(client, c.Cmd, COLLECT_TIMEOUT)
+		content := stdout
+		if stderr != "" {
+			content += "\n--- stderr ---\n" + stderr
+		}
+		content = redactSecrets(content)
+		e := CollectEntry{Kind: "cmd", Name: c.Name, Path: host + "/cmd/" + collectFileName(c.Name) + ".txt",
+			ExitCode: code, Bytes: len(content)}
+		if err != nil {
+			e.Error = err.Error()
+		}
+		ch.files[e.Path] = []byte(content)
+		ch.Entries = append(ch.Entries, e)
+	}
+
+	if len(spec.Files) == 0 {
+		return ch
+	}
+	// 先在远程展开通配符，只保留存在的普通文件
+	patterns := strings.Join(spec.Files, " ")
+	_, out, _, err := execCmd(client, "for f in "+patterns+"; do [ -f \"$f\" ] && echo \"$f\"; done", CMD_TIMEOUT)
+	if err != nil {
+		ch.Entries = append(ch.Entries, CollectEntry{Kind: "file", Name: patterns, ExitCode: -1, Error: err.Error()})
+		return ch
+	}
+	seen := make(map[string]bool) // 多个通配符可能匹配到同一文件
+	for _, path := range strings.Split(strings.TrimSpace(out), "\n") {
+		path = strings.TrimSpace(path)
+		if path == "" || seen[collectFilePath(path)] {
+			continue
+		}
+		seen[collectFilePath(path)] = true
+		cmd := fmt.Sprintf("tail -c %d %s", spec.MaxFileBytes, shellQuote(path))
+		code, stdout, stderr, err := execCmd(client, cmd, COLLECT_TIMEOUT)
+		e := CollectEntry{Kind: "file", Name: path, Path: host + "/files/" + collectFilePath(path),
+			ExitCode: code, Bytes: len(stdout)}
+		if err != nil {
+			e.Error = err.Error()
+		} else if code != 0 {
+			e.Error = strings.TrimSpace(stderr)
+		}
+		ch.files[e.Path] = []byte(redactSecrets(stdout))
+		ch.Entries = append(ch.Entries, e)
+	}
+	return ch
+}
+
+// runCollect 并发收集各主机的信息
+func runCollect(hosts []string, spec CollectSpec) []CollectHost {
+	results := make([]CollectHost, len(hosts))
+	var wg sync.WaitGroup
+	for i, h := range hosts {
+		wg.Add(1)
+		go func(i int, host string) {
+			defer wg.Done()
+			results[i] = collectHost(host, spec)
+		}(i, h)
+	}
+	wg.Wait()
+	return results
+}
+
+// writeCollectBundle 把收集结果打包为 tar.gz，包内文件都位于 prefix 目录下
+func writeCollectBundle(path, prefix string, manifest CollectManifest, last *HistoryRecord) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	now := time.Now()
+	writeFile := func(name string, data []byte) error {
+		hdr := &tar.Header{Name: prefix + "/" + name, Mode: 0644, Size: int64(len(data)), ModTime: now}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		_, err := tw.Write(data)
+		return err
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFile("manifest.json", data); err != nil {
+		return err
+	}
+	if last != nil {
+		data, err := json.MarshalIndent(last, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := writeFile("last_result.json", data); err != nil {
+			return err
+		}
+	}
+	for _, h := range manifest.Hosts {
+		for _, e := range h.Entries {
+			if e.Path == "" {
+				continue
+			}
+			if err := writeFile(e.Path, h.files[e.Path]); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
 	}
+	return gz.Close()
 }
 
-// ---------- df / mount ----------
-func parseSizeToGB(sizeStr string) (float64, bool) {
-	s := strings.TrimSpace(sizeStr)
-	re := regexp.MustCompile(`^([0-9]*\.?[0-9]+)\s*([KMGTP])([iI]?)B?$`)
-	m := re.FindStringSubmatch(s)
-	if m == nil {
-		return 0, false
+func collectMain(args []string) int {
+	fs := flag.NewFlagSet("collect", flag.ExitOnError)
+	specPath := fs.String("spec", "", "收集配置文件（JSON，格式见 collect.example.json），默认收集 dmesg、mount、df、topic 列表、进程、网络和日志")
+	outDir := fs.String("o", ".", "收集包输出目录")
+	vehicle := fs.String("vehicle", os.Getenv("CHECK_CAR_VEHICLE"), "车辆编号，用于选择清单中的车辆、文件名和最近一次检测结果")
+	inventoryPath := fs.String("inventory", "", "车队清单文件，指定后收集 -vehicle 对应车辆的主机")
+	hostsFlag := fs.String("hosts", "", "只收集指定主机（逗号分隔），默认车辆的全部主机")
+	operator := fs.String("operator", defaultOperator(), "操作员，记录到 manifest.json")
+	addJumpFlag(fs)
+	fs.Parse(args)
+
+	spec := DEFAULT_COLLECT_SPEC
+	if *specPath != "" {
+		var err error
+		if spec, err = loadCollectSpec(*specPath); err != nil {
+			fmt.Fprintf(os.Stderr, "读取收集配置失败: %v\n", err)
+			return 2
+		}
 	}
 
-	val, err := strconv.ParseFloat(m[1], 64)
+	v, err := selectVehicle(*inventoryPath, *vehicle)
 	if err != nil {
-		return 0, false
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	hosts := v.Hosts
+	if *hostsFlag != "" {
+		hosts = nil
+		for _, h := range strings.Split(*hostsFlag, ",") {
+			if h = strings.TrimSpace(h); h != "" {
+				hosts = append(hosts, h)
+			}
+		}
 	}
 
-	unit := strings.ToUpper(m[2])
-	factors := map[string]float64{
-		"K": 1.0 / (1024 * 1024),
-		"M": 1.0 / 1024,
-		"G": 1.0,
-		"T": 1024.0,
-		"P": 1024.0 * 1024.0,
+	// 附带该车最近一次检测结果
+	var last *HistoryRecord
+	if records, err := loadHistory(); err == nil {
+		for i := len(records) - 1; i >= 0; i-- {
+			if *vehicle == "" || records[i].VehicleID == *vehicle {
+				last = &records[i]
+				break
+			}
+		}
 	}
 
-	return val * factors[unit], true
-}
+	startTime := time.Now()
+	manifest := CollectManifest{
+		Timestamp: startTime.Format(time.RFC3339),
+		Vehicle:   *vehicle,
+		Operator:  *operator,
+		Hosts:     runCollect(hosts, spec),
+	}
+	manifest.Duration = float64(int(time.Since(startTime).Seconds()*10)) / 10
+	if last != nil {
+		manifest.LastHistoryID = last.ID
+	}
 
-func dfFindMountAvail(dfOut, nasIP string) (bool, string, float64, bool) {
-	for _, line := range strings.Split(dfOut, "\n") {
-		if strings.Contains(line, nasIP) {
-			parts := strings.Fields(line)
-			if len(parts) >= 6 {
-				availStr := parts[3]
-				availGB, ok := parseSizeToGB(availStr)
-				return true, availStr, availGB, ok
+	prefix := "check_car_bundle_" + startTime.Format("20060102_150405")
+	if *vehicle != "" {
+		prefix = "check_car_bundle_" + *vehicle + "_" + startTime.Format("20060102_150405")
+	}
+	path := filepath.Join(*outDir, prefix+".tar.gz")
+	if err := writeCollectBundle(path, prefix, manifest, last); err != nil {
+		fmt.Fprintf(os.Stderr, "写入收集包失败: %v\n", err)
+		return 1
+	}
+
+	rows := [][]string{{"主机", "命令", "文件", "失败", "说明"}}
+	collected := 0 // 收集到内容的主机数
+	for _, h := range manifest.Hosts {
+		if len(h.Entries) > 0 {
+			collected++
+		}
+		cmds, files, failed := 0, 0, 0
+		for _, e := range h.Entries {
+			if e.Kind == "cmd" {
+				cmds++
+			} else {
+				files++
+			}
+			if e.Error != "" {
+				failed++
 			}
-			return true, "", 0, false
 		}
+		rows = append(rows, []string{h.Host, strconv.Itoa(cmds), strconv.Itoa(files), strconv.Itoa(failed), h.Error})
+	}
+	printTextTable(rows)
+	if last != nil {
+		fmt.Printf("\n已附带最近一次检测结果: %s\n", last.ID)
+	} else {
+		fmt.Println("\n没有找到检测历史，未附带检测结果")
 	}
-	return false, "", 0, false
+	fmt.Printf("收集包: %s\n", path)
+	if collected == 0 {
+		fmt.Fprintln(os.Stderr, "所有主机都没有收集到内容")
+		return 1
+	}
+	return 0
 }
 
-func buildMountCmd(nasIP string) string {
-	mountOpts := fmt.Sprintf("vers=2.0,username=%s,password=%s,cache=strict,"+
-		"uid=1000,forceuid,gid=1000,forcegid,"+
-		"file_mode=0755,dir_mode=0755,soft,nounix,noserverino,mapposix,"+
-		"rsize=65536,wsize=65536,bsize=1048576,echo_interval=60,actimeo=1",
-		NAS_USER, NAS_PASS)
+// ---------- HTTP 服务 (serve) ----------
+
+//go:embed web/dist/index.html
+var embeddedIndexHTML []byte
 
-	return fmt.Sprintf("mkdir -p %s; umount -l %s 2>/dev/null || true; timeout %ds mount -t cifs //%s/nas %s -o %s",
-		MOUNT_POINT, MOUNT_POINT, MOUNT_TIMEOUT_SEC, nasIP, MOUNT_POINT, mountOpts)
+// Run 一次通过 HTTP 发起的检测
+type Run struct {
+	ID         string       `json:"id"`
+	Items      string       `json:"items"`
+	Vehicle    string       `json:"vehicle,omitempty"`
+	Operator   string       `json:"operator,omitempty"`
+	HistoryID  string       `json:"history_id,omitempty"`
+	Status     string       `json:"status"` // running / finished / cancelled
+	StartedAt  string       `json:"started_at"`
+	FinishedAt string       `json:"finished_at,omitempty"`
+	Result     *CheckResult `json:"result,omitempty"`
+
+	cancel context.CancelFunc
+	done   chan struct{}
+	events []Event
+	notify chan struct{} // 有新事件或检测结束时关闭并替换
 }
 
-func checkMountAlive(client *ssh.Client) bool {
-	code1, _, _, _ := execCmd(client, fmt.Sprintf("ls %s >/dev/null 2>&1", MOUNT_POINT), CMD_TIMEOUT)
-	if code1 != 0 {
-		return false
-	}
-	code2, _, _, _ := execCmd(client, fmt.Sprintf("touch %s/.__nas_test__ && rm -f %s/.__nas_test__ >/dev/null 2>&1", MOUNT_POINT, MOUNT_POINT), CMD_TIMEOUT)
-	return code2 == 0
+// checkServer 管理检测任务；同一时间只允许一个检测在跑，避免 pmupload 并发互相干扰
+type checkServer struct {
+	mu      sync.Mutex
+	runs    []*Run // 按开始时间排列，最多保留 SERVE_MAX_RUNS 条
+	current *Run
+	nextID  int
+	webDir  string
+
+	vehicle   string
+	operator  string
+	noHistory bool
+	inventory *Inventory // -inventory 指定时按请求中的 vehicle 从清单选车
 }
 
-func ensureMountAndGetDF(host, nasIP string) (bool, string, bool) {
-	didMountAttempt := false
-	client, err := sshConnect(host)
-	if err != nil {
-		return false, "", didMountAttempt
+// vehicleConfig 返回编号为 id 的车辆配置；未指定清单时只能检测默认车辆，
+// 避免把默认车辆的结果记到请求中的其他车辆名下
+func (s *checkServer) vehicleConfig(id string) (VehicleConfig, error) {
+	if s.inventory == nil {
+		if id != s.vehicle {
+			return VehicleConfig{}, fmt.Errorf("未指定 -inventory，只能检测默认车辆 %q", s.vehicle)
+		}
+		return defaultVehicle(), nil
 	}
-	defer client.Close()
-
-	_, dfOut, _, _ := execCmd(client, "df -h", CMD_TIMEOUT)
-	mounted, _, availGB, ok := dfFindMountAvail(dfOut, nasIP)
-	if mounted && ok && availGB >= MIN_AVAIL_GB && checkMountAlive(client) {
-		return true, dfOut, false
+	for _, v := range s.inventory.Vehicles {
+		if v.ID == id {
+			return v, nil
+		}
 	}
-
-	didMountAttempt = true
-	execCmd(client, buildMountCmd(nasIP), CMD_TIMEOUT)
-
-	_, dfOut2, _, _ := execCmd(client, "df -h", CMD_TIMEOUT)
-	mounted2, _, availGB2, ok2 := dfFindMountAvail(dfOut2, nasIP)
-	okResult := mounted2 && ok2 && availGB2 >= MIN_AVAIL_GB && checkMountAlive(client)
-	return okResult, dfOut2, true
+	return VehicleConfig{}, fmt.Errorf("清单中没有车辆 %q", id)
 }
 
-func checkMountRow(id int, item, host, nasIP string) internalResult {
-	mounted, dfOut, _ := ensureMountAndGetDF(host, nasIP)
+// startRun 在后台启动一次检测；已有检测在跑时返回 nil
+func (s *checkServer) startRun(req runRequest, v VehicleConfig) *Run {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	if !mounted {
-		return internalResult{id, item, false, "挂载失败或盘不可用（已自动清理并重挂一次），请换盘。"}
+	if s.current != nil {
+		return nil
 	}
 
-	m, availStr, availGB, ok := dfFindMountAvail(dfOut, nasIP)
-	if !m || !ok || availStr == "" {
-		return internalResult{id, item, false, "盘状态异常，请换盘。"}
+	s.nextID++
+	ctx, cancel := context.WithCancel(context.Background())
+	run := &Run{
+		ID:        strconv.Itoa(s.nextID),
+		Items:     req.Items,
+		Vehicle:   req.Vehicle,
+		Operator:  req.Operator,
+		Status:    "running",
+		StartedAt: time.Now().Format(time.RFC3339),
+		cancel:    cancel,
+		done:      make(chan struct{}),
+		notify:    make(chan struct{}),
 	}
-	if availGB < MIN_AVAIL_GB {
-		return internalResult{id, item, false, fmt.Sprintf("可用容量 %s（<800G），请换盘。", availStr)}
+	s.current = run
+	s.runs = append(s.runs, run)
+	if len(s.runs) > SERVE_MAX_RUNS {
+		s.runs = s.runs[len(s.runs)-SERVE_MAX_RUNS:]
 	}
 
-	return internalResult{id, item, true, fmt.Sprintf("可用容量 %s", availStr)}
-}
+	go func() {
+		defer cancel()
+		sinkCtx := withEventSink(ctx, func(ev Event) {
+			ev.RunID = run.ID
+			s.mu.Lock()
+			run.events = append(run.events, ev)
+			run.wakeLocked()
+			s.mu.Unlock()
+		})
+		result := runCheck(sinkCtx, v, parseItems(v, req.Items))
+		historyID := ""
+		if !s.noHistory {
+			historyID = saveHistory(req.Vehicle, req.Operator, req.Items, result)
+		}
 
-// ---------- pmupload parsing ----------
-func parsePmuploadWindows(text string) []int {
-	var windows []int
-	for _, line := range strings.Split(text, "\n") {
-		line = strings.TrimSpace(line)
-		if line == "" || !strings.HasPrefix(strings.TrimLeft(line, " \t"), "/") {
-			continue
+		s.mu.Lock()
+		run.Result = &result
+		run.HistoryID = historyID
+		run.FinishedAt = time.Now().Format(time.RFC3339)
+		if result.Cancelled {
+			run.Status = "cancelled"
+		} else {
+			run.Status = "finished"
 		}
-		m := PMUPLOAD_WINDOW_RE.FindStringSubmatch(line)
-		if m != nil {
-			if val, err := strconv.Atoi(m[1]); err == nil {
-				windows = append(windows, val)
-			}
+		run.wakeLocked()
+		s.current = nil
+		s.mu.Unlock()
+		close(run.done)
+	}()
+	return run
+}
+
+// wakeLocked 通知等待事件的 SSE 连接，调用方需持有 checkServer.mu
+func (r *Run) wakeLocked() {
+	close(r.notify)
+	r.notify = make(chan struct{})
+}
+
+func (s *checkServer) findRun(id string) *Run {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, r := range s.runs {
+		if r.ID == id {
+			return r
 		}
 	}
-	return windows
+	return nil
 }
 
-func allZero(arr []int) bool {
-	for _, v := range arr {
-		if v != 0 {
-			return false
+// snapshot 在锁内复制 Run，避免序列化时与后台任务并发读写
+func (s *checkServer) snapshot(r *Run) Run {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return *r
+}
+
+// lastFinished 返回最近一次已结束的检测
+func (s *checkServer) lastFinished() *Run {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := len(s.runs) - 1; i >= 0; i-- {
+		if s.runs[i].Result != nil {
+			return s.runs[i]
 		}
 	}
-	return true
+	return nil
 }
 
-func hasZero(arr []int) bool {
-	for _, v := range arr {
-		if v == 0 {
-			return true
+// lastFinishedByVehicle 返回每辆车最近一次完成的检测，按车辆编号排序
+func (s *checkServer) lastFinishedByVehicle() []VehicleResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	seen := make(map[string]bool)
+	var results []VehicleResult
+	for i := len(s.runs) - 1; i >= 0; i-- {
+		r := s.runs[i]
+		if r.Result == nil || seen[r.Vehicle] {
+			continue
 		}
+		seen[r.Vehicle] = true
+		results = append(results, VehicleResult{VehicleID: r.Vehicle, Result: *r.Result})
 	}
-	return false
+	sort.Slice(results, func(i, j int) bool { return results[i].VehicleID < results[j].VehicleID })
+	return results
 }
 
-func runPmuploadCheck(id int, host, itemName, cmd string) internalResult {
-	runOnce := func() []int {
-		client, err := sshConnect(host)
-		if err != nil {
-			return nil
-		}
-		defer client.Close()
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
 
-		_, out, errOut, _ := execCmd(client, cmd, PMUPLOAD_TIMEOUT)
-		merged := out
-		if out != "" && errOut != "" {
-			merged += "\n"
-		}
-		merged += errOut
-		if strings.TrimSpace(merged) == "" {
-			return nil
-		}
-		return parsePmuploadWindows(merged)
+func writeError(w http.ResponseWriter, code int, msg string) {
+	writeJSON(w, code, map[string]interface{}{"success": false, "error": msg})
+}
+
+// runRequest 启动检测的请求体，字段均可省略：items 为空表示全量检测，vehicle/operator 为空时使用服务启动参数；
+// 未指定 -inventory 时 vehicle 只能是默认车辆
+type runRequest struct {
+	Items    string `json:"items"`
+	Vehicle  string `json:"vehicle"`
+	Operator string `json:"operator"`
+}
+
+func (s *checkServer) readRunRequest(r *http.Request) runRequest {
+	var req runRequest
+	json.NewDecoder(r.Body).Decode(&req)
+	if req.Vehicle == "" {
+		req.Vehicle = s.vehicle
 	}
+	if req.Operator == "" {
+		req.Operator = s.operator
+	}
+	return req
+}
 
-	windows := runOnce()
-	if len(windows) == 0 || allZero(windows) {
-		windows = runOnce()
+// POST /api/runs 启动检测，立即返回
+func (s *checkServer) handleStartRun(w http.ResponseWriter, r *http.Request) {
+	req := s.readRunRequest(r)
+	v, err := s.vehicleConfig(req.Vehicle)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	run := s.startRun(req, v)
+	if run == nil {
+		writeError(w, http.StatusConflict, "检测正在进行中，请稍后")
+		return
 	}
+	writeJSON(w, http.StatusAccepted, s.snapshot(run))
+}
 
-	tipList := fmt.Sprintf("windows=%v", windows)
+// GET /api/runs 列出最近的检测（新的在前）
+func (s *checkServer) handleListRuns(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	list := make([]Run, 0, len(s.runs))
+	for i := len(s.runs) - 1; i >= 0; i-- {
+		list = append(list, *s.runs[i])
+	}
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, map[string]interface{}{"runs": list})
+}
 
-	addDriveTipPrefix := func(tip string) string {
-		if strings.Contains(cmd, "/lidar_side_front") {
-			return "请驾驶员挂D档并踩住刹车，" + tip
-		}
-		return tip
+// GET /api/runs/{id} 查询检测状态和结果
+func (s *checkServer) handleGetRun(w http.ResponseWriter, r *http.Request) {
+	run := s.findRun(r.PathValue("id"))
+	if run == nil {
+		writeError(w, http.StatusNotFound, "检测不存在")
+		return
 	}
+	writeJSON(w, http.StatusOK, s.snapshot(run))
+}
 
-	if len(windows) == 0 {
-		tip := fmt.Sprintf("可能并发过高/Topic未发布/跑错IP | %s | %s", cmd, tipList)
-		return internalResult{id, itemName, false, addDriveTipPrefix(tip)}
+// GET /api/runs/{id}/report 下载已完成检测的 HTML 报告
+func (s *checkServer) handleRunReport(w http.ResponseWriter, r *http.Request) {
+	run := s.findRun(r.PathValue("id"))
+	if run == nil {
+		writeError(w, http.StatusNotFound, "检测不存在")
+		return
+	}
+	snap := s.snapshot(run)
+	if snap.Result == nil {
+		writeError(w, http.StatusConflict, "检测尚未结束")
+		return
 	}
 
-	if hasZero(windows) {
-		tip := fmt.Sprintf("%s | %s", cmd, tipList)
-		return internalResult{id, itemName, false, addDriveTipPrefix(tip)}
+	var buf bytes.Buffer
+	meta := reportMeta{Vehicle: snap.Vehicle, Operator: snap.Operator, Items: snap.Items, HistoryID: snap.HistoryID}
+	if err := renderHTMLReport(&buf, meta, *snap.Result); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
 	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="check_report_%s.html"`, run.ID))
+	w.Write(buf.Bytes())
+}
 
-	return internalResult{id, itemName, true, tipList}
+// POST /api/runs/{id}/cancel 取消检测：不再启动新的检测项，已在执行的命令跑完各自超时后结束
+func (s *checkServer) handleCancelRun(w http.ResponseWriter, r *http.Request) {
+	run := s.findRun(r.PathValue("id"))
+	if run == nil {
+		writeError(w, http.StatusNotFound, "检测不存在")
+		return
+	}
+	run.cancel()
+	writeJSON(w, http.StatusAccepted, s.snapshot(run))
 }
 
-func runPmuploadGroup(host string, items []TopicCmd, startID, maxWorkers int, selected map[int]bool) []internalResult {
-	var results []internalResult
-	var mu sync.Mutex
-	sem := make(chan struct{}, maxWorkers)
-	var wg sync.WaitGroup
+// GET /api/runs/{id}/events 以 SSE 推送检测进度：先补发已产生的事件，再实时推送，检测结束后关闭
+func (s *checkServer) handleRunEvents(w http.ResponseWriter, r *http.Request) {
+	run := s.findRun(r.PathValue("id"))
+	if run == nil {
+		writeError(w, http.StatusNotFound, "检测不存在")
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "不支持流式输出")
+		return
+	}
 
-	for i, item := range items {
-		id := startID + i
-		if selected != nil && !selected[id] {
-			continue
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	sent := 0
+	for {
+		s.mu.Lock()
+		pending := append([]Event(nil), run.events[sent:]...)
+		notify := run.notify
+		finished := run.Result != nil
+		s.mu.Unlock()
+
+		for _, ev := range pending {
+			data, _ := json.Marshal(ev)
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Type, data)
 		}
-		wg.Add(1)
-		go func(id int, name, cmd string) {
-			defer wg.Done()
-			sem <- struct{}{}
-			defer func() { <-sem }()
+		sent += len(pending)
+		flusher.Flush()
 
-			result := runPmuploadCheck(id, host, name, cmd)
-			mu.Lock()
-			results = append(results, result)
-			mu.Unlock()
-		}(id, item.Name, item.Cmd)
+		if finished {
+			return
+		}
+		select {
+		case <-notify:
+		case <-r.Context().Done():
+			return
+		}
 	}
+}
 
-	wg.Wait()
-	return results
+// POST /api/check 同步检测，检测结束后返回 CheckResult（前端页面使用）
+func (s *checkServer) handleCheck(w http.ResponseWriter, r *http.Request) {
+	req := s.readRunRequest(r)
+	v, err := s.vehicleConfig(req.Vehicle)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	run := s.startRun(req, v)
+	if run == nil {
+		writeError(w, http.StatusConflict, "检测正在进行中，请稍后")
+		return
+	}
+	select {
+	case <-run.done:
+		writeJSON(w, http.StatusOK, s.snapshot(run).Result)
+	case <-r.Context().Done():
+		// 客户端断开时检测继续在后台完成，结果仍可通过 /api/runs 查询
+	}
 }
 
-// ---------- 检测逻辑 ----------
-func runCheck(selected map[int]bool) CheckResult {
-	startTime := time.Now()
-	var items []internalResult
+// GET /api/status 当前状态与最近一次结果（前端页面使用）
+func (s *checkServer) handleStatus(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	isChecking := s.current != nil
+	s.mu.Unlock()
 
-	// 1. 车机状态（必须先检测，只有成功后才继续）
-	carOK := true
-	for _, h := range HOSTS {
-		if !trySSH(h) {
-			carOK = false
-			break
-		}
+	resp := map[string]interface{}{"is_checking": isChecking, "last_result": nil, "last_check_time": nil}
+	if last := s.lastFinished(); last != nil {
+		snap := s.snapshot(last)
+		resp["last_result"] = snap.Result
+		resp["last_check_time"] = snap.FinishedAt
 	}
+	writeJSON(w, http.StatusOK, resp)
+}
 
-	// 无论用户是否选择检测项1，都记录车机状态结果
-	if selected == nil || selected[1] {
-		if carOK {
-			items = append(items, internalResult{1, "车机状态", true, ""})
-		} else {
-			items = append(items, internalResult{1, "车机状态", false, "请上电或插上网线"})
-		}
-	} else if !carOK {
-		// 用户没选择检测项1，但车机连不上，也要显示失败原因
-		items = append(items, internalResult{1, "车机状态", false, "请上电或插上网线（前置检测失败）"})
+// GET /api/result 最近一次检测结果（前端页面使用）
+func (s *checkServer) handleResult(w http.ResponseWriter, r *http.Request) {
+	last := s.lastFinished()
+	if last == nil {
+		writeError(w, http.StatusNotFound, "暂无检测结果，请先执行检测")
+		return
 	}
+	writeJSON(w, http.StatusOK, s.snapshot(last).Result)
+}
 
-	// 如果车机状态失败，直接返回，不继续后面的检测
-	if !carOK {
-		return buildResult(startTime, items)
+// GET /api/items 检测项列表，?vehicle= 指定清单中的车辆
+func (s *checkServer) handleItems(w http.ResponseWriter, r *http.Request) {
+	type item struct {
+		ID       int    `json:"id"`
+		Name     string `json:"name"`
+		Category string `json:"category"`
 	}
-
-	// 2. MDC1A 挂载
-	if selected == nil || selected[2] {
-		row2 := checkMountRow(2, fmt.Sprintf("%s MDC1A", MDC1_IP), MDC1_IP, NAS_160)
-		items = append(items, row2)
+	type entry struct {
+		Key  string `json:"key"`
+		Name string `json:"name"`
 	}
 
-	// 3. MDC2 挂载
-	if selected == nil || selected[3] {
-		row3 := checkMountRow(3, fmt.Sprintf("%s MDC2", MDC2_IP), MDC2_IP, NAS_60)
-		items = append(items, row3)
+	id := r.URL.Query().Get("vehicle")
+	if id == "" {
+		id = s.vehicle
+	}
+	v, err := s.vehicleConfig(id)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	start1, start2 := v.topicStartIDs()
+	items := []item{
+		{1, "车机状态", itemCategory(1)},
+		{2, fmt.Sprintf("%s (%s) NAS挂载", v.MDC1.Name, v.MDC1.Host), itemCategory(2)},
+		{3, fmt.Sprintf("%s (%s) NAS挂载", v.MDC2.Name, v.MDC2.Host), itemCategory(3)},
+	}
+	for i, t := range v.MDC1.Topics {
+		items = append(items, item{start1 + i, t.Name, itemCategory(start1 + i)})
+	}
+	for i, t := range v.MDC2.Topics {
+		items = append(items, item{start2 + i, t.Name, itemCategory(start2 + i)})
 	}
+	cat1, cat2 := v.catalogIDs()
+	items = append(items,
+		item{cat1, v.MDC1.Name + " Topic 目录", itemCategory(cat1)},
+		item{cat2, v.MDC2.Name + " Topic 目录", itemCategory(cat2)})
 
-	// 4-9. MDC1 Topics
-	mdc1Results := runPmuploadGroup(MDC1_IP, MDC1_TOPIC_CMDS, 4, MDC1_MAX_WORKERS, selected)
-	items = append(items, mdc1Results...)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"items": items,
+		"categories": []entry{
+			{"car", "车机状态"},
+			{"mount", "NAS挂载"},
+			{"topic", "Topic检测"},
+		},
+		"shortcuts": []entry{
+			{"all", "全量检测"},
+			{"car", "仅车机"},
+			{"mount", "仅挂载"},
+			{"topic", "仅Topic"},
+			{"catalog", "Topic目录比对"},
+			{"mdc1", "MDC1相关"},
+			{"mdc2", "MDC2相关"},
+		},
+	})
+}
 
-	// 10-13. MDC2 Topics
-	mdc2Results := runPmuploadGroup(MDC2_IP, MDC2_TOPIC_CMDS, 10, MDC2_MAX_WORKERS, selected)
-	items = append(items, mdc2Results...)
+// handleIndex 返回前端页面；指定 -web 目录时优先使用目录中的文件，否则使用编译时内嵌的页面
+func (s *checkServer) handleIndex(w http.ResponseWriter, r *http.Request) {
+	if strings.HasPrefix(r.URL.Path, "/api/") {
+		writeError(w, http.StatusNotFound, "Not found")
+		return
+	}
+	if s.webDir != "" {
+		http.ServeFile(w, r, filepath.Join(s.webDir, "index.html"))
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(embeddedIndexHTML)
+}
+
+// GET /metrics 各车辆最近一次检测结果的 Prometheus 指标
+func (s *checkServer) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	m := newMetricSet()
+	for _, vr := range s.lastFinishedByVehicle() {
+		addResultMetrics(m, vr.VehicleID, vr.Result)
+	}
+	s.mu.Lock()
+	running := s.current != nil
+	s.mu.Unlock()
+	m.add("check_car_running", "当前是否有检测在跑", boolGauge(running))
 
-	return buildResult(startTime, items)
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	io.WriteString(w, m.String())
 }
 
-// buildResult 构建返回结果
-func buildResult(startTime time.Time, items []internalResult) CheckResult {
-	// 分类到 passed 和 failed
-	passed := make(map[string]ResultItem)
-	failed := make(map[string]ResultItem)
+func (s *checkServer) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/runs", s.handleStartRun)
+	mux.HandleFunc("GET /api/runs", s.handleListRuns)
+	mux.HandleFunc("GET /api/runs/{id}", s.handleGetRun)
+	mux.HandleFunc("GET /api/runs/{id}/events", s.handleRunEvents)
+	mux.HandleFunc("GET /api/runs/{id}/report", s.handleRunReport)
+	mux.HandleFunc("POST /api/runs/{id}/cancel", s.handleCancelRun)
+	mux.HandleFunc("POST /api/check", s.handleCheck)
+	mux.HandleFunc("GET /api/status", s.handleStatus)
+	mux.HandleFunc("GET /api/result", s.handleResult)
+	mux.HandleFunc("GET /api/items", s.handleItems)
+	mux.HandleFunc("GET /metrics", s.handleMetrics)
+	mux.HandleFunc("/", s.handleIndex)
 
-	for _, item := range items {
-		key := strconv.Itoa(item.ID)
-		if item.OK {
-			passed[key] = ResultItem{Name: item.Name, Message: item.Message}
-		} else {
-			failed[key] = ResultItem{Name: item.Name, Message: item.Message}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
 		}
-	}
+		mux.ServeHTTP(w, r)
+	})
+}
 
-	duration := time.Since(startTime).Seconds()
+func serveMain(args []string) int {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	hostFlag := fs.String("host", "0.0.0.0", "监听地址")
+	portFlag := fs.Int("port", 5000, "监听端口")
+	webFlag := fs.String("web", "", "前端页面目录（默认使用内嵌的 web/dist/index.html）")
+	vehicleFlag := fs.String("vehicle", os.Getenv("CHECK_CAR_VEHICLE"), "默认车辆编号，记录到检测历史")
+	operatorFlag := fs.String("operator", defaultOperator(), "默认操作员，记录到检测历史")
+	noHistoryFlag := fs.Bool("no-history", false, "不保存检测历史")
+	inventoryPath := fs.String("inventory", "", "车队清单文件，指定后按请求中的 vehicle 选车检测")
+	addJumpFlag(fs)
+	addWorkersFlag(fs)
+	addBatchFlag(fs)
+	fs.Parse(args)
 
-	return CheckResult{
-		Timestamp:   time.Now().Format(time.RFC3339),
-		Success:     len(failed) == 0,
-		Duration:    duration,
-		Passed:      passed,
-		Failed:      failed,
-		FailedCount: len(failed),
-		TotalCount:  len(items),
+	s := &checkServer{
+		webDir:    *webFlag,
+		vehicle:   *vehicleFlag,
+		operator:  *operatorFlag,
+		noHistory: *noHistoryFlag,
+	}
+	if *inventoryPath != "" {
+		inv, err := loadInventory(*inventoryPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "读取车队清单失败: %v\n", err)
+			return 1
+		}
+		s.inventory = &inv
+	}
+	addr := net.JoinHostPort(*hostFlag, strconv.Itoa(*portFlag))
+	fmt.Fprintf(os.Stderr, "启动服务: http://%s\n", addr)
+	if err := http.ListenAndServe(addr, s.routes()); err != nil {
+		fmt.Fprintf(os.Stderr, "服务启动失败: %v\n", err)
+		return 1
 	}
+	return 0
 }
 
-func parseItems(itemsStr string) map[int]bool {
+func parseItems(v VehicleConfig, itemsStr string) map[int]bool {
 	if itemsStr == "" {
 		return nil // 全量检测
 	}
@@ -498,6 +5529,9 @@ func parseItems(itemsStr string) map[int]bool {
 	selected := make(map[int]bool)
 
 	// 支持别名
+	start1, start2 := v.topicStartIDs()
+	cat1, cat2 := v.catalogIDs()
+	maxID := v.maxItemID()
 	itemsStr = strings.ToLower(itemsStr)
 	switch itemsStr {
 	case "car":
@@ -508,19 +5542,23 @@ func parseItems(itemsStr string) map[int]bool {
 		selected[3] = true
 		return selected
 	case "topic", "topics":
-		for i := 4; i <= 13; i++ {
+		for i := start1; i <= v.lastTopicID(); i++ {
 			selected[i] = true
 		}
 		return selected
+	case "catalog":
+		selected[cat1] = true
+		selected[cat2] = true
+		return selected
 	case "mdc1":
 		selected[2] = true
-		for i := 4; i <= 9; i++ {
+		for i := start1; i < start2; i++ {
 			selected[i] = true
 		}
 		return selected
 	case "mdc2":
 		selected[3] = true
-		for i := 10; i <= 13; i++ {
+		for i := start2; i <= v.lastTopicID(); i++ {
 			selected[i] = true
 		}
 		return selected
@@ -532,7 +5570,7 @@ func parseItems(itemsStr string) map[int]bool {
 	parts := strings.Split(itemsStr, ",")
 	for _, p := range parts {
 		p = strings.TrimSpace(p)
-		if id, err := strconv.Atoi(p); err == nil && id >= 1 && id <= 13 {
+		if id, err := strconv.Atoi(p); err == nil && id >= 1 && id <= maxID {
 			selected[id] = true
 		}
 	}
@@ -547,14 +5585,90 @@ func printHelp() {
 	fmt.Println(`check_json - 车辆采集驾驶数据前环境健康检查工具（JSON输出版本）
 
 用法:
-  ./check_json                    # 全量检测（所有13项）
+  ./check_json                    # 全量检测（项1-13）
   ./check_json -items=1,2,3       # 只检测指定项（按ID）
   ./check_json -items=car         # 只检测车机状态（项1）
   ./check_json -items=mount       # 只检测挂载（项2,3）
   ./check_json -items=topic       # 只检测Topic（项4-13）
+  ./check_json -items=catalog     # 只比对 Topic 目录（项14,15，全量检测不含这两项，需显式指定）
   ./check_json -items=mdc1        # 只检测MDC1相关（项2,4-9）
   ./check_json -items=mdc2        # 只检测MDC2相关（项3,10-13）
-  ./check_json -items=all         # 全量检测
+  ./check_json -items=all         # 全量检测（项1-13）
+  ./check_json -events            # 以 NDJSON 逐行输出进度事件，可与 -items 组合
+  ./check_json -format=junit      # 以 JUnit XML 输出（按 car/mount/topic 分 testsuite），供 CI 展示
+  ./check_json -format=tap        # 以 TAP version 13 输出
+  ./check_json -max-workers=MDC1A=1,192.168.30.143=2
+                                  # 按主机或 MDC 名称调整 pmupload 最大并发数（也可用 CHECK_CAR_MAX_WORKERS），
+                                  # fleet check、serve 同样支持；并发时无输出的 Topic 会自动串行重跑
+  ./check_json -batch             # 同主机的 Topic 合并为一次 pmupload 调用（需 pmupload 支持，默认每个 Topic 单独执行）
+  ./check_json -transcript=run.log   # 同时保存各项执行的远程命令及输出（密码已脱敏）
+  ./check_json -record=session.tar   # 把本次检测的全部 SSH 交互录制到 tar 文件（密码已脱敏）
+  ./check_json -replay=session.tar   # 不连接车辆，按录制重跑检测（默认沿用录制时的 -items），
+                                     # 与录制时的结果比对，不一致时在 stderr 列出差异并以 3 退出
+  ./check_json -vehicle=V001 -report=report.html
+                                  # 同时生成单文件离线 HTML 报告（结果表格、各项详情、原始命令输出、自动修复动作）
+  ./check_json -vehicle=V001 -metrics-file=/var/lib/node_exporter/check_car.prom
+                                  # 同时写 Prometheus 指标文件（textfile collector），fleet check 同样支持
+
+采集后校验:
+  ./check_json verify [-since=T] [-until=T] [-dir=/mnt/share] [-max-gap=2m] [-inventory=F -vehicle=ID] [-expect=false]
+    检查各 MDC 录制目录中修改时间在 [since, until) 内的文件，按所在目录（相对录制目录的路径）
+    汇总文件数和总字节数，并报告时间断档（含窗口开始/结束处）、空文件、疑似截断文件，
+    以及配置中的 topic 完全没有录制文件的情况。
+    时间格式为 RFC3339 或 "2006-01-02 15:04:05"；默认校验最近 2 小时。
+
+检测历史:
+  每次检测（含 HTTP 服务发起的检测）都会追加保存到本地 history.jsonl，
+  目录为 $CHECK_CAR_DATA_DIR，默认 ~/.local/share/check_car。
+  ./check_json -vehicle=V001 -operator=张三   # 检测并记录车辆编号和操作员（-no-history 不保存）
+  ./check_json history list [-n=20] [-vehicle=V001]
+  ./check_json history show <id>             # 输出完整记录（JSON）
+  ./check_json history diff <a> <b>          # 对比两次检测的变化，id 可用 last、last~1
+  ./check_json history report <id> report.html   # 按历史记录补生成 HTML 报告
+  ./check_json report trends [-vehicle=V001] [-days=30] [-csv=trends.csv]
+    按检测历史输出各 Topic 平均 windows 和各 NAS 可用容量的趋势（sparkline），
+    标记多天持续下降的 Topic，可同时导出 CSV。
+
+跳板机:
+  ./check_json -jump=192.168.30.43               # 经车内网关连接 MDC
+  ./check_json -jump=ops@bastion:2222,192.168.30.43   # 多跳：先到车场跳板机，再到车内网关
+    也可用环境变量 CHECK_CAR_JUMP 指定；verify、serve、fleet check 同样支持 -jump。
+    车队清单中可为每台主机单独配置 proxy_jump（值可以是 hosts 中的别名），
+    跳板机的用户名/密码默认与车内主机相同，可在 hosts 中单独配置 user/password。
+
+车队检测:
+  ./check_json fleet check -inventory=inventory.json [-vehicles=V001,V002] [-items=mount]
+                           [-concurrency=N] [-json] [-operator=张三] [-no-history]
+    清单中每辆车有自己的主机、NAS 挂载目标和 Topic 配置（示例见 inventory.example.json），
+    hosts 可为主机名指定地址、端口和本机出口地址（local_addr，用于多网卡/VLAN）。
+    同时检测的车辆数不超过 -concurrency（默认取清单中的 max_concurrency），
+    输出每辆车的汇总表及未通过项详情；-json 输出每辆车的完整结果。
+
+Topic 发现:
+  ./check_json discover [-inventory=inventory.json -vehicle=V001]
+    列出各 MDC 当前发布的全部 Topic，在 stderr 列出未发布（-）和未登记（+）的 Topic，
+    在 stdout 输出可替换清单中 mdc1/mdc2 的 topics 配置片段（已登记的沿用原名称和命令）。
+    检测项 14/15 做同样的比对：已登记的 Topic 未发布时失败，只有未登记的 Topic 时通过并在提示中列出；
+    清单中 mdc 的 ignore_topics（支持通配符）可忽略已知无需检测的 Topic。
+
+故障信息收集:
+  ./check_json collect [-vehicle=V001] [-inventory=inventory.json] [-hosts=IP,IP] [-spec=collect.json] [-o=DIR]
+    并发连接车辆各主机，收集 dmesg、mount、df、pmupload topic 列表、进程、网络配置和日志文件，
+    连同该车最近一次检测结果打包为 check_car_bundle_<车辆>_<时间>.tar.gz，可直接附到问题单。
+    -spec 可自定义要执行的命令和要收集的文件（支持通配符），示例见 collect.example.json。
+
+HTTP 服务:
+  ./check_json serve [-host=0.0.0.0] [-port=5000] [-web=DIR]
+    POST /api/runs               启动检测，请求体 {"items": "mdc1", "vehicle": "...", "operator": "..."}，立即返回 run
+    GET  /api/runs               最近的检测列表
+    GET  /api/runs/{id}          检测状态和结果
+    GET  /api/runs/{id}/events   以 SSE 实时推送检测进度事件
+    GET  /api/runs/{id}/report   下载已结束检测的 HTML 报告
+    POST /api/runs/{id}/cancel   取消检测
+    POST /api/check              同步检测，结束后返回结果
+    GET  /api/status             是否正在检测及最近一次结果
+    GET  /metrics                各车辆最近一次检测的 Prometheus 指标
+    GET  /  前端页面（默认使用内嵌的 web/dist/index.html）
 
 检测项ID:
   1   车机状态
@@ -570,6 +5684,8 @@ func printHelp() {
   11  MDC2 右侧激光雷达
   12  MDC2 车顶激光雷达
   13  MDC2 左侧激光雷达
+  14  MDC1A Topic 目录（pmupload adstopic list 与已登记 Topic 比对）
+  15  MDC2 Topic 目录
 
 输出:
   JSON格式输出到stdout，包含:
@@ -578,13 +5694,59 @@ func printHelp() {
   - duration_seconds: 检测耗时
   - items: 检测结果列表
   - failed_count: 失败项数量
-  - total_count: 总检测项数量`)
+  - total_count: 总检测项数量
+  并发时无输出、串行重跑过的 Topic 检测项带 serial_retry: true，remediation 中说明重跑结果。
+  Topic 检测项另有 hz：各采样的 rate_hz、min/max_delta_seconds、std_dev_seconds、window 及汇总。
+  清单中 topics 可配置 min_rate_hz、max_jitter_seconds、max_gap_seconds，超出时判定失败。
+  配置了 probe 的 Topic 在 hz 通过后用 pmupload echo 抽检几条消息，结果在 content 中
+  （messages、points、stamps、frame_ids、problems），断言不满足时判定失败并给出原因。
+  配置了 latency 的 Topic 复用 hz 检测的 SSH 连接采样消息，比较到达 MDC 的时间与 header 时间戳，
+  结果在 latency 中（samples、p50_seconds、p95_seconds、max_seconds），超出预算时判定失败。
+  每项结果包含 duration_seconds（该项耗时）、output（判定所依据的原始命令输出）、
+  remediation（自动修复动作，如有）和 evidence（该项执行的每条远程命令：host、command、
+  start/end、exit_code、stdout、stderr、attempt、pty，密码已脱敏）。
+
+  指定 -events 时改为逐行输出进度事件（NDJSON），事件类型:
+  run_started, check_started, check_attempt, check_finished,
+  remediation_applied, run_finished（包含上述完整结果）`)
 }
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "verify":
+			os.Exit(verifyMain(os.Args[2:]))
+		case "serve":
+			os.Exit(serveMain(os.Args[2:]))
+		case "history":
+			os.Exit(historyMain(os.Args[2:]))
+		case "report":
+			os.Exit(reportMain(os.Args[2:]))
+		case "fleet":
+			os.Exit(fleetMain(os.Args[2:]))
+		case "collect":
+			os.Exit(collectMain(os.Args[2:]))
+		case "discover":
+			os.Exit(discoverMain(os.Args[2:]))
+		}
+	}
+
 	itemsFlag := flag.String("items", "", "要检测的项目，可以是ID列表(1,2,3)或别名(car,mount,topic,mdc1,mdc2,all)")
+	formatFlag := flag.String("format", "json", "结果输出格式: json / junit / tap")
+	reportFlag := flag.String("report", "", "同时生成单文件 HTML 报告，如 report.html")
+	transcriptFlag := flag.String("transcript", "", "同时把各项执行的远程命令及输出（已脱敏）保存为文本文件")
+	recordFlag := flag.String("record", "", "把本次检测的全部 SSH 交互录制到 tar 文件，如 session.tar")
+	replayFlag := flag.String("replay", "", "不连接车辆，按录制文件重跑检测并与录制时的结果比对")
+	metricsFileFlag := flag.String("metrics-file", "", "同时把结果写成 Prometheus 指标文件（node_exporter textfile collector，如 /var/lib/node_exporter/check_car.prom）")
+	eventsFlag := flag.Bool("events", false, "以 NDJSON 逐行输出检测进度事件（run_finished 事件中包含完整结果）")
+	vehicleFlag := flag.String("vehicle", os.Getenv("CHECK_CAR_VEHICLE"), "车辆编号，记录到检测历史")
+	operatorFlag := flag.String("operator", defaultOperator(), "操作员，记录到检测历史")
+	noHistoryFlag := flag.Bool("no-history", false, "不保存本次检测历史")
 	helpFlag := flag.Bool("help", false, "显示帮助信息")
 	flag.BoolVar(helpFlag, "h", false, "显示帮助信息")
+	addJumpFlag(flag.CommandLine)
+	addWorkersFlag(flag.CommandLine)
+	addBatchFlag(flag.CommandLine)
 
 	flag.Parse()
 
@@ -592,18 +5754,129 @@ func main() {
 		printHelp()
 		os.Exit(0)
 	}
+	switch *formatFlag {
+	case "json", "junit", "tap":
+	default:
+		fmt.Fprintf(os.Stderr, "不支持的输出格式: %s（可选 json / junit / tap）\n", *formatFlag)
+		os.Exit(2)
+	}
+	if *eventsFlag && *formatFlag != "json" {
+		fmt.Fprintln(os.Stderr, "-events 只能与 -format=json 一起使用")
+		os.Exit(2)
+	}
 
-	selected := parseItems(*itemsFlag)
-	result := runCheck(selected)
+	if *recordFlag != "" && *replayFlag != "" {
+		fmt.Fprintln(os.Stderr, "-record 与 -replay 不能同时使用")
+		os.Exit(2)
+	}
 
-	jsonBytes, err := json.MarshalIndent(result, "", "  ")
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "JSON序列化失败: %v\n", err)
+	var recorder *recordingExecutor
+	var replayer *replayExecutor
+	if *recordFlag != "" {
+		recorder = &recordingExecutor{inner: executor}
+		executor = recorder
+	}
+	if *replayFlag != "" {
+		var err error
+		replayer, err = loadRecording(*replayFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "加载录制文件失败: %v\n", err)
+			os.Exit(2)
+		}
+		executor = replayer
+		// 未指定 -items 时沿用录制时的检测项；回放不是真实检测，不保存历史
+		if *itemsFlag == "" {
+			*itemsFlag = replayer.meta.Items
+		}
+		*noHistoryFlag = true
+	}
+
+	v := defaultVehicle()
+	selected := parseItems(v, *itemsFlag)
+	ctx := context.Background()
+	if *eventsFlag {
+		ctx = withEventSink(ctx, ndjsonSink(os.Stdout))
+	}
+	result := runCheck(ctx, v, selected)
+	if recorder != nil {
+		meta := recordingMeta{
+			Time:    time.Now().Format(time.RFC3339),
+			Vehicle: *vehicleFlag,
+			Items:   *itemsFlag,
+			Jump:    defaultProxyJump,
+		}
+		if err := saveRecording(*recordFlag, meta, recorder.exchanges, result); err != nil {
+			fmt.Fprintf(os.Stderr, "保存录制文件失败: %v\n", err)
+		}
+	}
+	replayMismatch := false
+	if replayer != nil {
+		if problems := replayer.mismatches(); len(problems) > 0 {
+			replayMismatch = true
+			fmt.Fprintf(os.Stderr, "回放中有 %d 次交互与录制不符:\n", len(problems))
+			for _, p := range problems {
+				fmt.Fprintf(os.Stderr, "  %s\n", p)
+			}
+		}
+	}
+	if replayer != nil && replayer.result != nil {
+		if diffs := diffVerdicts(*replayer.result, result); len(diffs) > 0 {
+			replayMismatch = true
+			fmt.Fprintf(os.Stderr, "回放结果与录制时不一致（%d 项）:\n", len(diffs))
+			for _, d := range diffs {
+				fmt.Fprintf(os.Stderr, "  %s\n", d)
+			}
+		}
+	}
+	historyID := ""
+	if !*noHistoryFlag {
+		historyID = saveHistory(*vehicleFlag, *operatorFlag, *itemsFlag, result)
+	}
+	if *transcriptFlag != "" {
+		if err := saveTranscript(*transcriptFlag, result); err != nil {
+			fmt.Fprintf(os.Stderr, "保存命令记录失败: %v\n", err)
+		}
+	}
+	if *reportFlag != "" {
+		meta := reportMeta{Vehicle: *vehicleFlag, Operator: *operatorFlag, Items: *itemsFlag, HistoryID: historyID}
+		if err := writeHTMLReport(*reportFlag, meta, result); err != nil {
+			fmt.Fprintf(os.Stderr, "生成报告失败: %v\n", err)
+		}
+	}
+	if *metricsFileFlag != "" {
+		if err := writeMetricsFile(*metricsFileFlag, []VehicleResult{{VehicleID: *vehicleFlag, Result: result}}); err != nil {
+			fmt.Fprintf(os.Stderr, "写入指标文件失败: %v\n", err)
+		}
+	}
+
+	if *eventsFlag {
+		if result.Success && !replayMismatch {
+			os.Exit(0)
+		}
 		os.Exit(1)
 	}
 
-	fmt.Println(string(jsonBytes))
+	var err error
+	switch *formatFlag {
+	case "junit":
+		err = writeJUnit(os.Stdout, result)
+	case "tap":
+		err = writeTAP(os.Stdout, result)
+	default:
+		var jsonBytes []byte
+		jsonBytes, err = json.MarshalIndent(result, "", "  ")
+		if err == nil {
+			fmt.Println(string(jsonBytes))
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "输出结果失败: %v\n", err)
+		os.Exit(1)
+	}
 
+	if replayMismatch {
+		os.Exit(3)
+	}
 	if result.Success {
 		os.Exit(0)
 	} else {
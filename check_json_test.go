@@ -0,0 +1,1040 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 更新期望结果（testdata 中的 .golden.json 及 output 下的 JUnit/TAP/指标）: go test check_json.go check_json_test.go -args -update
+var updateGolden = flag.Bool("update", false, "用当前结果覆盖 testdata 中的期望结果")
+
+// runGolden 逐个解析 testdata/<dir>/*.txt，与同名 .golden.json 比对
+func runGolden(t *testing.T, dir string, parse func(string) interface{}) {
+	inputs, err := filepath.Glob(filepath.Join("testdata", dir, "*.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inputs) == 0 {
+		t.Fatalf("testdata/%s 中没有样例", dir)
+	}
+
+	for _, in := range inputs {
+		name := strings.TrimSuffix(filepath.Base(in), ".txt")
+		t.Run(name, func(t *testing.T) {
+			data, err := os.ReadFile(in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := json.MarshalIndent(parse(string(data)), "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, '\n')
+			compareGolden(t, strings.TrimSuffix(in, ".txt")+".golden.json", got)
+		})
+	}
+}
+
+// compareGolden 与期望结果文件比对；-update 时用 got 覆盖期望结果
+func compareGolden(t *testing.T, golden string, got []byte) {
+	t.Helper()
+	if *updateGolden {
+		if err := os.WriteFile(golden, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("读取期望结果失败（首次可加 -update 生成）: %v", err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("结果与 %s 不一致\n得到:\n%s\n期望:\n%s", golden, got, want)
+	}
+}
+
+// TestOutputFormats 以 testdata/output/*.json 中的检测结果生成 JUnit、TAP 和 Prometheus 指标，
+// 与同名的 .junit.xml、.tap、.prom 比对（分组、失败原因、跳过项、指标标签）
+func TestOutputFormats(t *testing.T) {
+	inputs, err := filepath.Glob(filepath.Join("testdata", "output", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inputs) == 0 {
+		t.Fatal("testdata/output 中没有样例")
+	}
+
+	for _, in := range inputs {
+		base := strings.TrimSuffix(in, ".json")
+		t.Run(filepath.Base(base), func(t *testing.T) {
+			data, err := os.ReadFile(in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var result CheckResult
+			if err := json.Unmarshal(data, &result); err != nil {
+				t.Fatal(err)
+			}
+
+			var junit, tap strings.Builder
+			if err := writeJUnit(&junit, result); err != nil {
+				t.Fatal(err)
+			}
+			compareGolden(t, base+".junit.xml", []byte(junit.String()))
+			if err := writeTAP(&tap, result); err != nil {
+				t.Fatal(err)
+			}
+			compareGolden(t, base+".tap", []byte(tap.String()))
+
+			m := newMetricSet()
+			addResultMetrics(m, "V001", result)
+			compareGolden(t, base+".prom", []byte(m.String()))
+		})
+	}
+}
+
+func TestParsePmuploadHz(t *testing.T) {
+	runGolden(t, "pmupload_hz", func(text string) interface{} { return parsePmuploadHz(text) })
+}
+
+func TestParseEchoMessages(t *testing.T) {
+	runGolden(t, "pmupload_echo", func(text string) interface{} { return parseEchoMessages(text) })
+}
+
+// TestParsePmuploadHzLegacyWindows 旧格式（行尾为 window）解析出的 windows 与原实现一致
+func TestParsePmuploadHzLegacyWindows(t *testing.T) {
+	text := "subscribed to [/dtof_left]\n/dtof_left 50\n  /dtof_left 0\nno new messages\n/dtof_left 1.5\n"
+	got := parsePmuploadHz(text).windows()
+	want := []int{50, 0}
+	if len(got) != len(want) {
+		t.Fatalf("windows=%v，期望 %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("windows=%v，期望 %v", got, want)
+		}
+	}
+}
+
+// TestPmuploadVerdictThresholds 配置阈值后按频率、抖动、最大间隔判定
+func TestPmuploadVerdictThresholds(t *testing.T) {
+	stats := summarizeHz([]HzSample{
+		{Topic: "/x", Rate: 9.5, MinDelta: 0.09, MaxDelta: 0.2, StdDev: 0.01, Window: 50},
+		{Topic: "/x", Rate: 9.7, MinDelta: 0.09, MaxDelta: 0.15, StdDev: 0.004, Window: 50},
+	})
+	cases := []struct {
+		name   string
+		topic  TopicCmd
+		ok     bool
+		reason string
+	}{
+		{"无阈值", TopicCmd{Name: "x", Cmd: "pmupload adstopic hz /x"}, true, ""},
+		{"频率", TopicCmd{Name: "x", Cmd: "pmupload adstopic hz /x", MinRate: 9.8}, false, "频率"},
+		{"抖动", TopicCmd{Name: "x", Cmd: "pmupload adstopic hz /x", MaxJitter: 0.005}, false, "抖动"},
+		{"最大间隔", TopicCmd{Name: "x", Cmd: "pmupload adstopic hz /x", MaxGap: 0.18}, false, "最大间隔"},
+		{"阈值内", TopicCmd{Name: "x", Cmd: "pmupload adstopic hz /x", MinRate: 9, MaxJitter: 0.02, MaxGap: 0.25}, true, ""},
+	}
+	for _, c := range cases {
+		r := pmuploadVerdict(1, c.topic, stats, NO_SAMPLE_SILENT)
+		if r.OK != c.ok || !strings.Contains(r.Message, c.reason) {
+			t.Errorf("%s: ok=%v message=%q", c.name, r.OK, r.Message)
+		}
+	}
+
+	// 只有 window 的输出无法比较阈值，判定通过并注明
+	windowOnly := summarizeHz([]HzSample{{Topic: "/x", Window: 50}, {Topic: "/x", Window: 48}})
+	r := pmuploadVerdict(1, TopicCmd{Name: "x", Cmd: "pmupload adstopic hz /x", MinRate: 9.8, MaxGap: 0.18}, windowOnly, NO_SAMPLE_SILENT)
+	if !r.OK || !strings.Contains(r.Message, "没有频率/间隔数据") {
+		t.Errorf("无计时数据: ok=%v message=%q", r.OK, r.Message)
+	}
+}
+
+// TestEvaluateContent 内容抽检的各项断言
+func TestEvaluateContent(t *testing.T) {
+	good := []echoMessage{
+		{Stamp: 100.1, HasStamp: true, FrameID: "lidar", HasFrameID: true, Points: 5000, HasPoints: true, PayloadBytes: 80000},
+		{Stamp: 100.2, HasStamp: true, FrameID: "lidar", HasFrameID: true, Points: 4990, HasPoints: true, PayloadBytes: 79840},
+	}
+	frozen := []echoMessage{good[0], good[0]}
+	empty := []echoMessage{{Stamp: 1, HasStamp: true, PayloadBytes: 0}, {Stamp: 2, HasStamp: true, PayloadBytes: 0}}
+
+	cases := []struct {
+		name   string
+		probe  ContentProbe
+		msgs   []echoMessage
+		reason string // 为空表示应通过
+	}{
+		{"全部通过", ContentProbe{NonEmpty: true, MinPoints: 1000, TimestampAdvancing: true, FrameID: "lidar"}, good, ""},
+		{"没有消息", ContentProbe{NonEmpty: true}, nil, "未采到消息"},
+		{"内容为空", ContentProbe{NonEmpty: true}, empty, "内容为空"},
+		{"点数不足", ContentProbe{MinPoints: 4995}, good, "点数 4990 少于 4995"},
+		{"帧冻结", ContentProbe{TimestampAdvancing: true}, frozen, "未前进"},
+		{"frame_id", ContentProbe{FrameID: "base_link"}, good, "frame_id"},
+		{"未配置断言", ContentProbe{}, empty, ""},
+	}
+	for _, c := range cases {
+		cr := evaluateContent(c.probe, "echo", c.msgs)
+		got := strings.Join(cr.Problems, "；")
+		if c.reason == "" && got != "" || c.reason != "" && !strings.Contains(got, c.reason) {
+			t.Errorf("%s: problems=%q，期望包含 %q", c.name, got, c.reason)
+		}
+	}
+}
+
+func TestParseLatencySamples(t *testing.T) {
+	runGolden(t, "pmupload_latency", func(text string) interface{} { return parseLatencySamples(text) })
+}
+
+// TestEvaluateLatency 分位数按最近秩法计算，超出预算时给出原因
+func TestEvaluateLatency(t *testing.T) {
+	samples := []float64{0.05, 0.01, 0.03, 0.02, 0.04, 0.06, 0.07, 0.08, 0.09, 0.5}
+	lr := evaluateLatency(LatencyCheck{BudgetP95: 0.2}, "echo", samples)
+	if lr.P50 != 0.05 || lr.P95 != 0.5 || lr.Max != 0.5 {
+		t.Fatalf("p50=%v p95=%v max=%v", lr.P50, lr.P95, lr.Max)
+	}
+	if !strings.Contains(lr.Problem, "p95") {
+		t.Errorf("problem=%q，期望超出 p95 预算", lr.Problem)
+	}
+	if lr := evaluateLatency(LatencyCheck{BudgetP50: 0.1}, "echo", samples); lr.Problem != "" {
+		t.Errorf("p50 在预算内，problem=%q", lr.Problem)
+	}
+	if lr := evaluateLatency(LatencyCheck{}, "echo", nil); lr.Problem == "" {
+		t.Error("没有样本时应失败")
+	}
+}
+
+// flakyExecutor 模拟并发过高：每个 topic 的前 failures 次 pmupload 没有输出（检测时的两次尝试），之后正常；
+// healthy 中的 topic 始终正常
+type flakyExecutor struct {
+	mu       sync.Mutex
+	failures int
+	healthy  map[string]bool
+	calls    map[string]int
+}
+
+func (e *flakyExecutor) Dial(host, scope string) (Conn, error) {
+	return &flakyConn{e}, nil
+}
+
+type flakyConn struct {
+	e *flakyExecutor
+}
+
+func (c *flakyConn) Exec(cmd string, timeout time.Duration) (int, string, string, error) {
+	c.e.mu.Lock()
+	defer c.e.mu.Unlock()
+	c.e.calls[cmd]++
+	if c.e.calls[cmd] <= c.e.failures && !c.e.healthy[topicOfCmd(cmd)] {
+		return 0, "", "", nil
+	}
+	return 0, topicOfCmd(cmd) + " window: 50\n", "", nil
+}
+
+func (c *flakyConn) Close() error { return nil }
+
+// TestRunPmuploadGroupSerialRetry 同组有其他 topic 通过时，并发下无输出的项串行重跑，并标记 serial_retry
+func TestRunPmuploadGroupSerialRetry(t *testing.T) {
+	saved := executor
+	defer func() { executor = saved }()
+
+	topics := []TopicCmd{
+		{Name: "a", Cmd: "pmupload adstopic hz /a"},
+		{Name: "b", Cmd: "pmupload adstopic hz /b"},
+		{Name: "c", Cmd: "pmupload adstopic hz /c"},
+	}
+	healthy := map[string]bool{"/c": true}
+	executor = &flakyExecutor{failures: 2, healthy: healthy, calls: map[string]int{}}
+	var mu sync.Mutex
+	var events []Event
+	ctx := withEventSink(context.Background(), func(ev Event) {
+		mu.Lock()
+		events = append(events, ev)
+		mu.Unlock()
+	})
+	results := runPmuploadGroup(ctx, "mdc", topics, 4, 2, nil)
+	if len(results) != 3 {
+		t.Fatalf("得到 %d 项结果", len(results))
+	}
+	for _, r := range results {
+		retried := r.Name != "c"
+		if !r.OK || r.SerialRetry != retried || (retried && !strings.Contains(r.Message, "串行重跑通过")) {
+			t.Errorf("%d. %s: ok=%v serial_retry=%v message=%q", r.ID, r.Name, r.OK, r.SerialRetry, r.Message)
+		}
+		// 串行重跑的命令记录追加在并发执行之后：第 1、2 次无输出，第 3 次通过
+		if retried {
+			var attempts []int
+			for _, e := range r.Evidence {
+				if n := len(attempts); n == 0 || attempts[n-1] != e.Attempt {
+					attempts = append(attempts, e.Attempt)
+				}
+			}
+			if fmt.Sprint(attempts) != "[1 2 3]" {
+				t.Errorf("%s: evidence attempts=%v", r.Name, attempts)
+			}
+		}
+	}
+	// 每项只有一次 check_started 和 check_finished，重跑的 check_attempt 接着编号
+	started, finished := map[int]int{}, map[int]int{}
+	lastAttempt := map[int]int{}
+	for _, ev := range events {
+		switch ev.Type {
+		case EVENT_CHECK_STARTED:
+			started[ev.ID]++
+		case EVENT_CHECK_FINISHED:
+			finished[ev.ID]++
+			if !*ev.OK {
+				t.Errorf("%d. %s: check_finished ok=false", ev.ID, ev.Name)
+			}
+		case EVENT_CHECK_ATTEMPT:
+			if ev.Attempt != lastAttempt[ev.ID]+1 {
+				t.Errorf("%d. %s: attempt %d 接在 %d 之后", ev.ID, ev.Name, ev.Attempt, lastAttempt[ev.ID])
+			}
+			lastAttempt[ev.ID] = ev.Attempt
+		}
+	}
+	for _, r := range results {
+		if started[r.ID] != 1 || finished[r.ID] != 1 {
+			t.Errorf("%d. %s: check_started %d 次，check_finished %d 次", r.ID, r.Name, started[r.ID], finished[r.ID])
+		}
+	}
+
+	// 串行重跑仍无输出时不再提示并发过高
+	executor = &flakyExecutor{failures: 4, healthy: healthy, calls: map[string]int{}}
+	for _, r := range runPmuploadGroup(context.Background(), "mdc", topics, 4, 2, nil) {
+		if r.Name != "c" && (r.OK || !r.SerialRetry || strings.Contains(r.Message, "并发过高")) {
+			t.Errorf("仍失败: %d. %s ok=%v serial_retry=%v message=%q", r.ID, r.Name, r.OK, r.SerialRetry, r.Message)
+		}
+	}
+
+	// 全组都无输出时不是并发问题，不串行重跑
+	executor = &flakyExecutor{failures: 2, calls: map[string]int{}}
+	for _, r := range runPmuploadGroup(context.Background(), "mdc", topics, 4, 2, nil) {
+		if r.OK || r.SerialRetry {
+			t.Errorf("全组失败: %d. %s ok=%v serial_retry=%v", r.ID, r.Name, r.OK, r.SerialRetry)
+		}
+	}
+
+	// 并发为 1 时不会串行重跑
+	executor = &flakyExecutor{failures: 2, healthy: healthy, calls: map[string]int{}}
+	for _, r := range runPmuploadGroup(context.Background(), "mdc", topics, 4, 1, nil) {
+		if r.Name != "c" && (r.OK || r.SerialRetry) {
+			t.Errorf("并发 1: %d. %s ok=%v serial_retry=%v", r.ID, r.Name, r.OK, r.SerialRetry)
+		}
+	}
+
+	// SSH 连接失败或 pmupload 有输出（如报错）时不串行重跑
+	for _, out := range []string{"SSH 连接失败: dial tcp: i/o timeout", "topic /a does not exist"} {
+		if concurrencyStarved(internalResult{Output: out}) {
+			t.Errorf("output=%q 不应视为并发导致", out)
+		}
+	}
+}
+
+func TestWorkerOverrides(t *testing.T) {
+	saved := workerOverrides
+	workerOverrides = map[string]int{}
+	defer func() { workerOverrides = saved }()
+
+	if err := parseWorkerOverrides("MDC1A=1, 192.168.30.143=3", workerOverrides); err != nil {
+		t.Fatal(err)
+	}
+	if err := parseWorkerOverrides("mdc2=0", workerOverrides); err == nil {
+		t.Error("并发数为 0 时应报错")
+	}
+	cases := []struct {
+		mdc  MDCConfig
+		want int
+	}{
+		{MDCConfig{Name: "MDC1A", Host: "192.168.30.41", MaxWorkers: 2}, 1},
+		{MDCConfig{Name: "MDC2", Host: "192.168.30.143", MaxWorkers: 4}, 3},
+		{MDCConfig{Name: "MDC3", Host: "10.0.0.1", MaxWorkers: 5}, 5},
+		{MDCConfig{Name: "MDC4", Host: "10.0.0.2"}, 1},
+	}
+	for _, c := range cases {
+		if got := c.mdc.workers(); got != c.want {
+			t.Errorf("%s: workers=%d，期望 %d", c.mdc.Name, got, c.want)
+		}
+	}
+}
+
+// batchExecutor 按命令返回固定输出；合并命令只输出 topics 中的 topic，单独执行时全部正常
+type batchExecutor struct {
+	mu     sync.Mutex
+	topics []string
+	cmds   []string
+}
+
+func (e *batchExecutor) Dial(host, scope string) (Conn, error) {
+	return &batchConn{e}, nil
+}
+
+type batchConn struct {
+	e *batchExecutor
+}
+
+func (c *batchConn) Exec(cmd string, timeout time.Duration) (int, string, string, error) {
+	c.e.mu.Lock()
+	defer c.e.mu.Unlock()
+	c.e.cmds = append(c.e.cmds, cmd)
+	if len(strings.Fields(cmd)) <= len(strings.Fields(PMUPLOAD_HZ_CMD))+1 {
+		return 0, topicOfCmd(cmd) + " window: 50\n", "", nil
+	}
+	var b strings.Builder
+	for _, t := range c.e.topics {
+		fmt.Fprintf(&b, "%s rate: 10.0 window: 50\n", t)
+	}
+	return 0, b.String(), "", nil
+}
+
+func (c *batchConn) Close() error { return nil }
+
+// TestRunPmuploadGroupBatch 合并执行时按 topic 拆分结果，缺失的 topic 和不支持合并的主机回退为逐个执行
+func TestRunPmuploadGroupBatch(t *testing.T) {
+	saved, savedBatch := executor, batchEnabled
+	defer func() { executor, batchEnabled = saved, savedBatch }()
+
+	// 默认不合并
+	e := &batchExecutor{topics: []string{"/a", "/b", "/c"}}
+	executor = e
+	batchEnabled = false
+	runPmuploadGroup(context.Background(), "batch-off", []TopicCmd{{Name: "a", Cmd: PMUPLOAD_HZ_CMD + " /a"}, {Name: "b", Cmd: PMUPLOAD_HZ_CMD + " /b"}}, 4, 2, nil)
+	if len(e.cmds) != 2 {
+		t.Errorf("未开启 -batch 时执行了 %q", e.cmds)
+	}
+	batchEnabled = true
+
+	topics := []TopicCmd{
+		{Name: "a", Cmd: PMUPLOAD_HZ_CMD + " /a"},
+		{Name: "b", Cmd: PMUPLOAD_HZ_CMD + " /b"},
+		{Name: "c", Cmd: PMUPLOAD_HZ_CMD + " /c"},
+		{Name: "custom", Cmd: "pmupload adstopic hz /d --raw"},
+	}
+	cases := []struct {
+		host        string
+		output      []string
+		wantBatched map[string]bool
+		wantCmds    int
+		unsupported bool
+	}{
+		{"batch-ok", []string{"/a", "/b"}, map[string]bool{"a": true, "b": true}, 3, false},
+		{"batch-first-only", []string{"/a"}, map[string]bool{}, 5, true},
+		{"batch-empty", nil, map[string]bool{}, 5, true},
+	}
+	for _, c := range cases {
+		e := &batchExecutor{topics: c.output}
+		executor = e
+		results := runPmuploadGroup(context.Background(), c.host, topics, 4, 2, nil)
+		if len(results) != len(topics) {
+			t.Fatalf("%s: 得到 %d 项结果", c.host, len(results))
+		}
+		for _, r := range results {
+			if !r.OK || r.Batched != c.wantBatched[r.Name] {
+				t.Errorf("%s: %s ok=%v batched=%v message=%q", c.host, r.Name, r.OK, r.Batched, r.Message)
+			}
+		}
+		if len(e.cmds) != c.wantCmds {
+			t.Errorf("%s: 执行了 %d 条命令 %q，期望 %d", c.host, len(e.cmds), e.cmds, c.wantCmds)
+		}
+		if _, got := batchUnsupported.Load(c.host); got != c.unsupported {
+			t.Errorf("%s: unsupported=%v，期望 %v", c.host, got, c.unsupported)
+		}
+	}
+
+	// 已记录为不支持的主机不再尝试合并
+	e = &batchExecutor{topics: []string{"/a", "/b", "/c"}}
+	executor = e
+	runPmuploadGroup(context.Background(), "batch-first-only", topics, 4, 2, nil)
+	if len(e.cmds) != len(topics) {
+		t.Errorf("不支持合并的主机执行了 %d 条命令 %q", len(e.cmds), e.cmds)
+	}
+}
+
+func TestParseVehicleState(t *testing.T) {
+	text := `header:
+  stamp: 1760000000.1
+chassis.gear_location: GEAR_P
+brake_pedal_status: 1
+ignition = "on"
+gear_location: D`
+	got := parseVehicleState(text, []string{"brake", "gear", "ignition"},
+		map[string]string{"gear": "gear_location", "brake": "brake_pedal_status"})
+	want := map[string]string{"gear": "P", "brake": "ON", "ignition": "ON"}
+	for name, v := range want {
+		if got[name] != v {
+			t.Errorf("%s=%q，期望 %q", name, got[name], v)
+		}
+	}
+
+	requires := map[string]string{"gear": "D|R", "brake": "pressed"}
+	if unmet := unmetPreconditions(requires, got); strings.Join(unmet, " ") != "gear=P" {
+		t.Errorf("unmet=%q", unmet)
+	}
+	if unmet := unmetPreconditions(requires, map[string]string{"gear": "R", "brake": "ON"}); len(unmet) != 0 {
+		t.Errorf("满足时 unmet=%q", unmet)
+	}
+	if unmet := unmetPreconditions(requires, map[string]string{"gear": "D"}); strings.Join(unmet, " ") != "brake=未知" {
+		t.Errorf("缺少刹车状态时 unmet=%q", unmet)
+	}
+}
+
+// stateExecutor hz 命令没有输出，车辆状态命令返回 state
+type stateExecutor struct {
+	state string
+}
+
+func (e stateExecutor) Dial(host, scope string) (Conn, error) { return e, nil }
+
+func (e stateExecutor) Exec(cmd string, timeout time.Duration) (int, string, string, error) {
+	if cmd == DEFAULT_VEHICLE_STATE_CMD {
+		return 0, e.state, "", nil
+	}
+	return 0, "", "", nil
+}
+
+func (e stateExecutor) Close() error { return nil }
+
+// TestRunPmuploadCheckPrecondition Topic 未发布时按车辆状态区分前置条件未满足和传感器故障
+func TestRunPmuploadCheckPrecondition(t *testing.T) {
+	saved := executor
+	defer func() { executor = saved }()
+
+	topic := TopicCmd{Name: "lidar", Cmd: PMUPLOAD_HZ_CMD + " /lidar_side_front",
+		Requires: map[string]string{"gear": "D", "brake": "on"}}
+	v := VehicleConfig{MDC1: MDCConfig{Host: "mdc1", Topics: []TopicCmd{topic}}}
+	cases := []struct {
+		state        string
+		precondition string
+		message      string
+	}{
+		{"gear: P\nbrake: 0\n", "brake=OFF gear=P", "precondition not met: brake=OFF gear=P"},
+		{"gear: D\nbrake: 1\n", "", "车辆状态满足"},
+		{"", "", "车辆状态读取失败"},
+	}
+	for _, c := range cases {
+		executor = stateExecutor{c.state}
+		r := runPmuploadCheck(withVehicleState(context.Background(), v), 4, "mdc1", topic)
+		if r.OK || r.Precondition != c.precondition || !strings.HasPrefix(r.Message, c.message) {
+			t.Errorf("state=%q: ok=%v precondition=%q message=%q", c.state, r.OK, r.Precondition, r.Message)
+		}
+	}
+}
+
+// TestBuildResultSkipsPrecondition 前置条件未满足的项列入 skipped，不计为失败，JUnit/TAP/指标中标记为跳过
+func TestBuildResultSkipsPrecondition(t *testing.T) {
+	result := buildResult(time.Now(), []internalResult{
+		{ID: 1, Name: "车机状态", OK: true},
+		{ID: 9, Name: "MDC1A 前向激光雷达", Precondition: "gear=P", Message: "precondition not met: gear=P"},
+	})
+	if !result.Success || result.FailedCount != 0 || result.SkippedCount != 1 || len(result.Skipped) != 1 {
+		t.Fatalf("success=%v failed=%d skipped=%d", result.Success, result.FailedCount, result.SkippedCount)
+	}
+
+	var junit, tap strings.Builder
+	if err := writeJUnit(&junit, result); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(junit.String(), `failures="0" skipped="1"`) || !strings.Contains(junit.String(), "<skipped") {
+		t.Errorf("junit=%s", junit.String())
+	}
+	if err := writeTAP(&tap, result); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(tap.String(), "ok 2 - 9. MDC1A 前向激光雷达 # SKIP precondition not met: gear=P") || strings.Contains(tap.String(), "not ok") {
+		t.Errorf("tap=%s", tap.String())
+	}
+
+	m := newMetricSet()
+	addResultMetrics(m, "V001", result)
+	metrics := m.String()
+	if !strings.Contains(metrics, "check_car_run_success{vehicle=\"V001\"} 1") ||
+		!strings.Contains(metrics, "check_car_run_skipped_items{vehicle=\"V001\"} 1") ||
+		strings.Contains(metrics, `check_car_check_pass{vehicle="V001",host="",id="9"`) {
+		t.Errorf("metrics=%s", metrics)
+	}
+}
+
+// scriptExecutor 按命令内容返回结果，并记录执行过的命令
+type scriptExecutor struct {
+	mu   sync.Mutex
+	run  func(cmd string) (int, string, string, error)
+	cmds []string
+}
+
+func (e *scriptExecutor) Dial(host, scope string) (Conn, error) { return scriptConn{e}, nil }
+
+type scriptConn struct {
+	e *scriptExecutor
+}
+
+func (c scriptConn) Exec(cmd string, timeout time.Duration) (int, string, string, error) {
+	c.e.mu.Lock()
+	c.e.cmds = append(c.e.cmds, cmd)
+	c.e.mu.Unlock()
+	return c.e.run(cmd)
+}
+
+func (c scriptConn) Close() error { return nil }
+
+// ran 返回执行过的包含 substr 的命令数
+func (e *scriptExecutor) ran(substr string) int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	n := 0
+	for _, cmd := range e.cmds {
+		if strings.Contains(cmd, substr) {
+			n++
+		}
+	}
+	return n
+}
+
+// TestEnsureMountBusyCheckTimeout 挂载卡死导致占用检查超时时拒绝重挂，并说明检查未完成
+func TestEnsureMountBusyCheckTimeout(t *testing.T) {
+	saved := executor
+	defer func() { executor = saved }()
+
+	e := &scriptExecutor{run: func(cmd string) (int, string, string, error) {
+		if strings.Contains(cmd, "fuser") {
+			return 124, "", "", nil
+		}
+		return 1, "", "", nil // df 中没有挂载，ls/touch 失败
+	}}
+	executor = e
+	targets := []MountTarget{{Type: MOUNT_CIFS, Source: "//192.168.79.160/nas"}}
+	st := ensureMount("mdc", targets, &evidenceLog{})
+	if st.BusyErr == nil || st.Remounted || e.ran("umount") > 0 {
+		t.Fatalf("busy_err=%v remounted=%v cmds=%q", st.BusyErr, st.Remounted, e.cmds)
+	}
+	if tip := mountTip(st, targets); !strings.Contains(tip, "无法确认") || !strings.Contains(tip, "卡死") {
+		t.Errorf("tip=%q", tip)
+	}
+}
+
+// TestEnsureMountSkipsFullTarget 已挂载但容量不足的首选不再重挂，提示列出每个候选的结果
+func TestEnsureMountSkipsFullTarget(t *testing.T) {
+	saved := executor
+	defer func() { executor = saved }()
+
+	targets := []MountTarget{
+		{Type: MOUNT_CIFS, Source: "//192.168.79.160/nas"},
+		{Type: MOUNT_CIFS, Source: "//192.168.79.60/nas"},
+	}
+	for _, backupOK := range []bool{true, false} {
+		mounted := "//192.168.79.160/nas  20T  19.9T  100G  99% /mnt/share"
+		e := &scriptExecutor{}
+		e.run = func(cmd string) (int, string, string, error) {
+			switch {
+			case strings.HasPrefix(cmd, "df"):
+				return 0, "Filesystem Size Used Avail Use% Mounted on\n" + mounted + "\n", "", nil
+			case strings.Contains(cmd, "mount -t cifs"):
+				if !backupOK {
+					return 32, "", "mount error(112): Host is down", nil
+				}
+				mounted = "//192.168.79.60/nas  20T  10T  10T  50% /mnt/share"
+			}
+			return 0, "", "", nil
+		}
+		executor = e
+		st := ensureMount("mdc", targets, &evidenceLog{})
+		if n := e.ran("mount -t cifs"); n != 1 || e.ran("mount -t cifs //192.168.79.160") != 0 {
+			t.Errorf("backup_ok=%v: 重挂 %d 次 cmds=%q", backupOK, n, e.cmds)
+		}
+		tip := mountTip(st, targets)
+		if backupOK {
+			if !st.OK || st.Target != targets[1] || !strings.Contains(tip, "已切换到备用") {
+				t.Errorf("ok=%v target=%v tip=%q", st.OK, st.Target, tip)
+			}
+			continue
+		}
+		for _, want := range []string{"重挂 1 个候选", "//192.168.79.160/nas 可用容量 100G", "未重挂", "//192.168.79.60/nas NAS 主机不可达"} {
+			if st.OK || !strings.Contains(tip, want) {
+				t.Errorf("tip=%q 中缺少 %q", tip, want)
+			}
+		}
+	}
+}
+
+// TestProbeTargetAmbiguous 只有超时或明确的拒绝/不可达才算探测失败，nc 不支持 -z 等情况交给 mount 判断
+func TestProbeTargetAmbiguous(t *testing.T) {
+	saved := executor
+	defer func() { executor = saved }()
+
+	cases := []struct {
+		code           int
+		out            string
+		passed, probed bool
+	}{
+		{0, "", true, true},
+		{124, "", false, true},
+		{1, "bash: connect: Connection refused\n", false, true},
+		{1, "nc: connect to 192.168.79.160 port 445 (tcp) failed: No route to host\n", false, true},
+		{1, "nc: invalid option -- 'z'\nBusyBox v1.30.1 multi-call binary.\n", false, false},
+		{1, "", false, false},
+		{127, "", false, false},
+	}
+	for _, c := range cases {
+		executor = &scriptExecutor{run: func(string) (int, string, string, error) { return c.code, c.out, "", nil }}
+		client, err := dialHost("mdc", nil, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		passed, probed, _ := probeTarget(client, MountTarget{Type: MOUNT_CIFS, Source: "//192.168.79.160/nas"})
+		if passed != c.passed || probed != c.probed {
+			t.Errorf("code=%d out=%q: passed=%v probed=%v", c.code, c.out, passed, probed)
+		}
+	}
+}
+
+func TestDfFindMountAvail(t *testing.T) {
+	df := `Filesystem            Size  Used Avail Use% Mounted on
+/dev/nvme0n1p10       3.5T  100G  3.4T   3% /mnt/share
+/dev/nvme0n1p1        3.5T  3.4T  100G  98% /data
+//192.168.79.160/nas   20T   10T   10T  50% /mnt/nas160
+192.168.79.200:/export/nas/
+                       20T   19T  1.0T  95% /mnt/share`
+	cases := []struct {
+		t       MountTarget
+		mounted bool
+		avail   string
+	}{
+		{MountTarget{Type: MOUNT_LOCAL, Source: "/dev/nvme0n1p1"}, false, ""},
+		{MountTarget{Type: MOUNT_LOCAL, Source: "/dev/nvme0n1p10"}, true, "3.4T"},
+		{MountTarget{Type: MOUNT_CIFS, Source: "//192.168.79.160/nas"}, false, ""},
+		{MountTarget{Type: MOUNT_NFS, Source: "192.168.79.200:/export/nas"}, true, "1.0T"},
+	}
+	for _, c := range cases {
+		mounted, avail, _, _ := dfFindMountAvail(df, c.t)
+		if mounted != c.mounted || avail != c.avail {
+			t.Errorf("%s: mounted=%v avail=%q", c.t.Source, mounted, avail)
+		}
+	}
+}
+
+// TestVerifyGroups 断档包含窗口首尾，分组按相对路径区分，期望的 topic 没有文件时列为缺失
+func TestVerifyGroups(t *testing.T) {
+	since := time.Date(2026, 1, 2, 9, 0, 0, 0, time.Local)
+	until := since.Add(30 * time.Minute)
+	at := func(min int) time.Time { return since.Add(time.Duration(min) * time.Minute) }
+
+	if got := recordGroupName("front/lidar/0001.pcd"); got != "front/lidar" {
+		t.Errorf("recordGroupName=%q", got)
+	}
+	if got := recordGroupName("0001.bag"); got != "." {
+		t.Errorf("根目录文件分组=%q", got)
+	}
+
+	var files []recordFile
+	for min := 10; min <= 20; min++ {
+		files = append(files, recordFile{Path: fmt.Sprintf("front/lidar/%d.pcd", min), Size: 100, MTime: at(min)})
+	}
+	g := analyzeRecordGroup("front/lidar", files, since, until, 2*time.Minute)
+	if len(g.Gaps) != 2 || !strings.HasPrefix(g.Gaps[0], "开始 09:00:00 ~ 09:10:00") || !strings.Contains(g.Gaps[1], "~ 结束 09:30:00") {
+		t.Errorf("gaps=%q", g.Gaps)
+	}
+
+	groups := map[string][]recordFile{
+		"front/lidar":          files,
+		"20260102/dtof_left":   files,
+		"rear/lidar_side_rear": files,
+	}
+	missing := missingGroups([]string{"front/lidar", "dtof_left", "/lidar_side_roof", "rear/lidar"}, groups)
+	if strings.Join(missing, ",") != "lidar_side_roof,rear/lidar" {
+		t.Errorf("missing=%q", missing)
+	}
+}
+
+// TestJumpHopsAuth 跳板机按用户、地址和端口判断是否为目标自身；非车内账号不默认使用车内密码
+func TestJumpHopsAuth(t *testing.T) {
+	defer func(saved map[string]HostConfig) { hostConfigs = saved }(hostConfigs)
+	hostConfigs = map[string]HostConfig{
+		"bastion": {Addr: "10.20.0.5", Port: 2222, User: "ops", IdentityFile: filepath.Join(t.TempDir(), "missing")},
+	}
+
+	hc := resolveHost("192.168.30.43")
+	hc.ProxyJump = "ops@192.168.30.43:2222,192.168.30.43,bastion"
+	if hops := jumpHops("192.168.30.43", hc); strings.Join(hops, ",") != "ops@192.168.30.43:2222" {
+		t.Errorf("hops=%q", hops)
+	}
+
+	if hc := resolveHost("ops@10.20.0.6"); hc.Password != "" || !hc.Agent {
+		t.Errorf("临时跳板机 password=%q agent=%v", hc.Password, hc.Agent)
+	}
+	if _, _, err := sshAuthMethods(resolveHost("bastion")); err == nil || !strings.Contains(err.Error(), "读取私钥失败") {
+		t.Errorf("私钥不存在: err=%v", err)
+	}
+	t.Setenv("SSH_AUTH_SOCK", "")
+	if _, _, err := sshAuthMethods(resolveHost("ops@10.20.0.6")); err == nil || !strings.Contains(err.Error(), "没有可用的认证方式") {
+		t.Errorf("无认证方式: err=%v", err)
+	}
+	if auth, _, err := sshAuthMethods(resolveHost("192.168.30.41")); err != nil || len(auth) != 1 {
+		t.Errorf("车内主机 auth=%d err=%v", len(auth), err)
+	}
+}
+
+// TestParseItemsCatalogOptIn Topic 目录比对只在显式指定时执行
+func TestParseItemsCatalogOptIn(t *testing.T) {
+	v := defaultVehicle()
+	cat1, cat2 := v.catalogIDs()
+	for _, items := range []string{"", "all", "topic", "mdc1", "mdc2"} {
+		if sel := parseItems(v, items); sel[cat1] || sel[cat2] {
+			t.Errorf("-items=%q 包含了目录比对", items)
+		}
+	}
+	if sel := parseItems(v, "catalog"); !sel[cat1] || !sel[cat2] || len(sel) != 2 {
+		t.Errorf("-items=catalog: %v", sel)
+	}
+	if sel := parseItems(v, fmt.Sprintf("%d", cat2)); !sel[cat2] {
+		t.Errorf("按 ID 指定: %v", sel)
+	}
+}
+
+// TestRunLatencyCheckLineBuffered 时延命令以 stdbuf -oL 运行 pmupload，并据输出开头的标记记录是否行缓冲
+func TestRunLatencyCheckLineBuffered(t *testing.T) {
+	saved := executor
+	defer func() { executor = saved }()
+
+	sample, err := os.ReadFile(filepath.Join("testdata", "pmupload_latency", "ros1_fusion.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	topic := TopicCmd{Name: "x", Cmd: "timeout 8s pmupload adstopic hz /object_array_fusion", Latency: &LatencyCheck{Count: 3}}
+	for _, buffered := range []bool{true, false} {
+		out := string(sample)
+		if buffered {
+			out = LATENCY_LINEBUF_MARK + "\n" + out
+		}
+		executor = &scriptExecutor{run: func(cmd string) (int, string, string, error) { return 0, out, "", nil }}
+		client, err := dialHost("mdc", nil, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lr, _ := runLatencyCheck(client, topic)
+		if !strings.Contains(lr.Cmd, "stdbuf -oL") || lr.LineBuffered != buffered || len(lr.Samples) != len(parseLatencySamples(string(sample))) {
+			t.Errorf("buffered=%v: line_buffered=%v samples=%v cmd=%q", buffered, lr.LineBuffered, lr.Samples, lr.Cmd)
+		}
+	}
+}
+
+// TestServeVehicleConfig serve 未指定清单时拒绝检测默认车辆以外的车辆，指定清单时按编号选车
+func TestServeVehicleConfig(t *testing.T) {
+	s := &checkServer{vehicle: "V001"}
+	if _, err := s.vehicleConfig("V001"); err != nil {
+		t.Errorf("默认车辆: %v", err)
+	}
+	if _, err := s.vehicleConfig("V002"); err == nil {
+		t.Error("未指定清单时检测其他车辆应报错")
+	}
+
+	s.inventory = &Inventory{Vehicles: []VehicleConfig{{ID: "V002", MDC1: MDCConfig{Host: "10.0.2.41"}}}}
+	if v, err := s.vehicleConfig("V002"); err != nil || v.MDC1.Host != "10.0.2.41" {
+		t.Errorf("清单中的车辆: %+v %v", v.MDC1, err)
+	}
+	if _, err := s.vehicleConfig("V001"); err == nil {
+		t.Error("清单中没有的车辆应报错")
+	}
+}
+
+// TestCollectTrendsRate 有频率数据时额外记录频率序列，windows 不变而频率下降也能判断为持续下降
+func TestCollectTrendsRate(t *testing.T) {
+	start := time.Date(2026, 3, 1, 10, 0, 0, 0, time.Local)
+	var records []HistoryRecord
+	for day, rate := range []float64{10, 9, 7, 5} {
+		hz := HzStats{Samples: []HzSample{{Topic: "/a", Rate: rate, Window: 100}}, MeanRate: rate}
+		records = append(records, HistoryRecord{VehicleID: "V001", Result: CheckResult{
+			Timestamp: start.AddDate(0, 0, day).Format(time.RFC3339),
+			Passed:    map[string]ResultItem{"4": {Name: "lidar", Windows: []int{100}, Hz: &hz}},
+		}})
+	}
+	records = append(records, HistoryRecord{VehicleID: "V001", Result: CheckResult{
+		Timestamp: start.AddDate(0, 0, 4).Format(time.RFC3339),
+		Passed:    map[string]ResultItem{"4": {Name: "lidar", Windows: []int{100}, Hz: &HzStats{Samples: []HzSample{{Window: 100}}}}},
+	}})
+
+	series := collectTrends(records, "V001", start.AddDate(0, 0, -1))
+	kinds := make(map[string]*trendSeries)
+	for _, ts := range series {
+		kinds[ts.Kind] = ts
+	}
+	if kinds["topic"] == nil || len(kinds["topic"].Points) != 5 {
+		t.Fatalf("windows 序列: %+v", kinds["topic"])
+	}
+	if kinds["rate"] == nil || len(kinds["rate"].Points) != 4 {
+		t.Fatalf("频率序列（旧格式记录不计入）: %+v", kinds["rate"])
+	}
+	if drift, _ := detectDrift(kinds["topic"].Points); drift {
+		t.Error("windows 不变时不应判断为下降")
+	}
+	if drift, msg := detectDrift(kinds["rate"].Points); !drift {
+		t.Errorf("频率下降应判断为持续下降: %q", msg)
+	}
+}
+
+// TestRedactSecrets 命令记录中的 password=/token= 参数、SSH/NAS 密码和清单中各主机的密码都替换为 ***
+func TestRedactSecrets(t *testing.T) {
+	defer func(saved map[string]HostConfig) { hostConfigs = saved }(hostConfigs)
+	hostConfigs = map[string]HostConfig{
+		"bastion": {Addr: "10.20.0.5", User: "ops", Password: "B4stion!pw"},
+	}
+
+	cifs := buildMountCmd(MountTarget{Type: MOUNT_CIFS, Source: "//192.168.30.160/nas"})
+	cases := []struct {
+		name   string
+		in     string
+		secret []string // 脱敏后不应再出现的内容
+		keep   []string // 脱敏后应保留的内容
+	}{
+		{"password=", "curl -u x 'https://nas/api?user=a&password=hunter2'", []string{"hunter2"}, []string{"password=***", "user=a"}},
+		{"token=", "upload token=abc.def-123 --retry 3", []string{"abc.def-123"}, []string{"token=***", "--retry 3"}},
+		{"带前缀的变量", "export NAS_PASS=s3cret && mount", []string{"s3cret"}, []string{"NAS_PASS=***", "&& mount"}},
+		{"cifs 挂载参数", cifs, []string{NAS_PASS}, []string{"username=" + NAS_USER, "password=***", "cache=strict"}},
+		{"自定义挂载参数中的 NAS 密码", "mount -o user=nas,pw=" + NAS_PASS + ",vers=3.0", []string{NAS_PASS}, []string{"vers=3.0"}},
+		{"SSH 密码", "sshpass -p '" + PASSWORD + "' ssh root@192.168.30.41", []string{PASSWORD}, []string{"root@192.168.30.41"}},
+		{"主机密码", "ssh: handshake failed for ops (password B4stion!pw)", []string{"B4stion!pw"}, []string{"handshake failed"}},
+	}
+	for _, c := range cases {
+		got := redactSecrets(c.in)
+		for _, s := range c.secret {
+			if strings.Contains(got, s) {
+				t.Errorf("%s: %q 中仍有 %q", c.name, got, s)
+			}
+		}
+		for _, s := range c.keep {
+			if !strings.Contains(got, s) {
+				t.Errorf("%s: %q 中缺少 %q", c.name, got, s)
+			}
+		}
+	}
+}
+
+// dialFailExecutor 连接 failHost 的第 failFrom 次起、共 failCount 次失败，其余交给 scriptExecutor；
+// slowScope 检测项的连接先等待一会，使录制时的连接顺序与回放时不同
+type dialFailExecutor struct {
+	*scriptExecutor
+	failHost            string
+	failFrom, failCount int
+	slowScope           string
+	dials               int
+}
+
+func (e *dialFailExecutor) Dial(host, scope string) (Conn, error) {
+	if scope == e.slowScope {
+		time.Sleep(20 * time.Millisecond)
+	}
+	e.mu.Lock()
+	if host == e.failHost {
+		e.dials++
+		if e.dials >= e.failFrom && e.dials < e.failFrom+e.failCount {
+			e.mu.Unlock()
+			return nil, fmt.Errorf("connection reset by peer")
+		}
+	}
+	e.mu.Unlock()
+	return e.scriptExecutor.Dial(host, scope)
+}
+
+// dialErrors 返回每个检测项命令记录中连接失败的次数
+func dialErrors(result CheckResult) map[int]int {
+	ids, items, _ := resultItems(result)
+	n := make(map[int]int)
+	for _, id := range ids {
+		for _, e := range items[id].Evidence {
+			if e.Error != "" {
+				n[id]++
+			}
+		}
+	}
+	return n
+}
+
+// TestRecordReplay 录制后回放：并发检测中的偶发连接失败回放给录制时的同一检测项，
+// 回放时的并发数不同也得到一致的结论和命令记录；交互用完（多出的连接或命令）时视为与录制不一致
+func TestRecordReplay(t *testing.T) {
+	saved := executor
+	defer func() { executor = saved }()
+
+	v := defaultVehicle()
+	selected := parseItems(v, "topic")
+	_, start2 := v.topicStartIDs()
+	inner := &dialFailExecutor{
+		scriptExecutor: &scriptExecutor{run: func(cmd string) (int, string, string, error) {
+			topic := topicOfCmd(cmd)
+			return 0, topic + " window: 50\n" + topic + " window: 50\n", "", nil
+		}},
+		failHost: v.MDC2.Host, failFrom: 2, failCount: 3, // 车机检测之后、各 Topic 并发连接时的三次失败
+		slowScope: strconv.Itoa(start2 + 3),
+	}
+	rec := &recordingExecutor{inner: inner}
+	executor = rec
+	recorded := runCheck(context.Background(), v, selected)
+	want := dialErrors(recorded)
+	if len(want) == 0 {
+		t.Fatal("录制中没有连接失败")
+	}
+
+	path := filepath.Join(t.TempDir(), "session.tar")
+	if err := saveRecording(path, recordingMeta{Items: "topic"}, rec.exchanges, recorded); err != nil {
+		t.Fatal(err)
+	}
+	serial := v
+	serial.MDC2.MaxWorkers = 1
+	for i, rv := range []VehicleConfig{v, serial, v, serial} {
+		replayer, err := loadRecording(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		executor = replayer
+		got := runCheck(context.Background(), rv, selected)
+		if diffs := diffVerdicts(*replayer.result, got); len(diffs) > 0 {
+			t.Fatalf("第 %d 次回放结论不一致: %q", i+1, diffs)
+		}
+		if problems := replayer.mismatches(); len(problems) > 0 {
+			t.Fatalf("第 %d 次回放交互不符: %q", i+1, problems)
+		}
+		if g := dialErrors(got); fmt.Sprint(g) != fmt.Sprint(want) {
+			t.Fatalf("第 %d 次回放各项连接失败次数 %v，录制时 %v", i+1, g, want)
+		}
+
+		// 同一录制再跑一次，所有交互都已用完
+		runCheck(context.Background(), rv, selected)
+		if len(replayer.mismatches()) == 0 {
+			t.Fatal("交互用完后应视为不一致")
+		}
+	}
+}
+
+// TestCollectHostPaths 收集的文件在包内保留目录结构，a_b 与 a/b 不会互相覆盖，同一文件只收集一次
+func TestCollectHostPaths(t *testing.T) {
+	saved := executor
+	defer func() { executor = saved }()
+
+	executor = &scriptExecutor{run: func(cmd string) (int, string, string, error) {
+		if strings.HasPrefix(cmd, "for f in") {
+			return 0, "/var/log/a_b\n/var/log/a/b\n/var/log/../log/a_b\n", "", nil
+		}
+		return 0, cmd, "", nil
+	}}
+	ch := collectHost("mdc", CollectSpec{Files: []string{"/var/log/a*", "/var/log/a/*"}, MaxFileBytes: 1024})
+	var paths []string
+	for _, e := range ch.Entries {
+		paths = append(paths, e.Path)
+	}
+	want := []string{"mdc/files/var/log/a_b", "mdc/files/var/log/a/b"}
+	if strings.Join(paths, ",") != strings.Join(want, ",") {
+		t.Fatalf("paths=%q，期望 %q", paths, want)
+	}
+	if string(ch.files[want[0]]) == string(ch.files[want[1]]) {
+		t.Errorf("两个文件的内容相同: %q", ch.files[want[0]])
+	}
+	if p := collectFilePath("../../etc/passwd"); p != "etc/passwd" {
+		t.Errorf("collectFilePath=%q", p)
+	}
+}